@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"embed"
-	"fmt"
+	"github.com/alpha-omega-corp/cloud/api/pkg/types"
 	"github.com/alpha-omega-corp/cloud/api/pkg/user"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
 	"github.com/alpha-omega-corp/cloud/core"
 	"github.com/alpha-omega-corp/cloud/core/config"
+	"github.com/alpha-omega-corp/cloud/core/discovery"
+	"github.com/alpha-omega-corp/cloud/core/health"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	"github.com/alpha-omega-corp/cloud/core/security"
+	"github.com/alpha-omega-corp/cloud/core/server"
 	"github.com/uptrace/bunrouter"
-	"log"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -16,17 +23,54 @@ var (
 )
 
 func main() {
-	core.NewApp(embedFS, "gateway").
-		CreateApi(func(router *bunrouter.Router, configHandler *config.Handler) {
-			configUser, err := configHandler.GetConfig("user")
-			if err != nil {
-				log.Fatal(err.Error())
-			}
+	cfg := new(types.Config)
 
-			fmt.Println(configUser)
+	app := core.NewApp(embedFS, "gateway").
+		WithConfig(cfg)
+	app.CreateApi(func(router *bunrouter.Router, configHandler *config.Handler) {
+		creds, err := security.ClientCredentials(app.Config().TLS)
+		if err != nil {
+			logging.Fatal("user client error", "error", err)
+		}
 
-			svcUser := user.NewClient(configUser)
-			user.RegisterClient(svcUser, router)
+		conn, err := discovery.Dial(configHandler.Client(), "user", app.Config().Services["user"],
+			grpc.WithTransportCredentials(creds),
+			grpc.WithChainUnaryInterceptor(server.PropagateUnary()),
+			grpc.WithChainStreamInterceptor(server.PropagateStream()),
+		)
+		if err != nil {
+			logging.Fatal("user client error", "error", err)
+		}
+
+		app.Health().Add("user", health.GRPC(conn))
+		app.Lifecycle().Append(core.Hook{
+			Name: "user client",
+			OnStop: func(ctx context.Context) error {
+				return conn.Close()
+			},
 		})
 
+		svcUser := user.NewClient(conn)
+
+		verify := user.NewVerifier(svcUser.Self())
+		if cfg.LocalVerify {
+			auth := utils.NewAuthWrapper(cfg.Secret.Value(),
+				utils.WithKeySource(utils.NewRemoteKeys(svcUser.Self(), utils.DefaultKeysTTL)),
+				utils.WithIssuer(cfg.Issuer),
+				utils.WithAudience(cfg.Audience),
+				utils.WithLeeway(cfg.ClockSkew),
+			)
+			auth.SetHMACMigration(cfg.HMACMigration)
+			config.WatchAs(configHandler, "gateway", func(c *types.Config) {
+				auth.Update(utils.Signing{
+					Secret:        c.Secret.Value(),
+					HMACMigration: c.HMACMigration,
+				})
+			})
+
+			verify = user.NewLocalVerifier(auth)
+		}
+
+		user.RegisterClient(svcUser, verify, user.NewAuthorizer(svcUser.Self()), router)
+	})
 }
@@ -0,0 +1,26 @@
+package types
+
+import (
+	st "github.com/alpha-omega-corp/cloud/core/types"
+	"time"
+)
+
+// Config of the gateway. With LocalVerify set, the tokens are verified with
+// the public keys published by the user service instead of calling its
+// Validate RPC. Revoked tokens are then accepted until they expire, and the
+// permissions they carry are used over the ones of the user service. Issuer,
+// Audience and ClockSkew match the ones the user service signs the tokens
+// with.
+//
+// With LocalVerify set, HMAC tokens are only verified with Secret while
+// HMACMigration is set, as the user service moves to keys. Anyone knowing
+// Secret can forge tokens meanwhile. Without LocalVerify both are ignored,
+// the user service accepts HMAC tokens under its own hmac_migration.
+type Config struct {
+	LocalVerify   bool          `mapstructure:"local_verify"`
+	Secret        st.Secret     `mapstructure:"secret"`
+	HMACMigration bool          `mapstructure:"hmac_migration"`
+	Issuer        string        `mapstructure:"issuer" default:"auth-svc"`
+	Audience      string        `mapstructure:"audience" default:"cloud"`
+	ClockSkew     time.Duration `mapstructure:"clock_skew" default:"30s"`
+}
@@ -0,0 +1,104 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/httputils"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+)
+
+type (
+	userKey   struct{}
+	matrixKey struct{}
+)
+
+// UserFrom returns the user authenticated by the request, if any.
+func UserFrom(ctx context.Context) (*proto.User, bool) {
+	user, ok := ctx.Value(userKey{}).(*proto.User)
+	return user, ok
+}
+
+func WithUser(ctx context.Context, user *proto.User) context.Context {
+	ctx = context.WithValue(ctx, userKey{}, user)
+	return logging.With(ctx, logging.KeyUserID, user.GetId())
+}
+
+// MatrixFrom returns the permissions carried by the token of the request, if
+// it was verified locally and carries them.
+func MatrixFrom(ctx context.Context) (authz.Matrix, bool) {
+	matrix, ok := ctx.Value(matrixKey{}).(authz.Matrix)
+	return matrix, ok
+}
+
+// NewVerifier authenticates the tokens with the Validate RPC of the user
+// service, so that deleted users are rejected.
+func NewVerifier(client proto.UserServiceClient) httputils.Verifier {
+	return func(ctx context.Context, token string) (context.Context, error) {
+		res, err := client.Validate(ctx, &proto.ValidateRequest{Token: token})
+		if err != nil {
+			return nil, err
+		}
+
+		return WithUser(ctx, res.GetUser()), nil
+	}
+}
+
+// NewLocalVerifier authenticates the tokens with the keys of the user
+// service, without a round trip to it. Revoked tokens are accepted until
+// they expire, as are the permissions they carry.
+func NewLocalVerifier(auth *utils.AuthWrapper) httputils.Verifier {
+	return func(ctx context.Context, token string) (context.Context, error) {
+		claims, err := auth.ValidateToken(ctx, token)
+		if errors.Is(err, utils.ErrInvalidToken) {
+			return nil, httputils.Unauthorized("%v", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if matrix, ok := claims.Matrix(); ok {
+			ctx = context.WithValue(ctx, matrixKey{}, matrix)
+		}
+
+		roles := make([]*proto.Role, 0, len(claims.Roles))
+		for _, role := range claims.Roles {
+			roles = append(roles, &proto.Role{Name: role})
+		}
+
+		return WithUser(ctx, &proto.User{
+			Id:    claims.UserId,
+			Email: claims.Email,
+			Roles: roles,
+		}), nil
+	}
+}
+
+// NewAuthorizer checks the permissions of the authenticated user against the
+// matrix carried by its token, or else computed by the user service.
+func NewAuthorizer(client proto.UserServiceClient, opts ...authz.Option) *authz.Authorizer {
+	return authz.NewAuthorizer(
+		func(ctx context.Context) (int64, error) {
+			user, ok := UserFrom(ctx)
+			if !ok {
+				return 0, grpcutils.Unauthenticated("no authenticated user")
+			}
+			return user.GetId(), nil
+		},
+		func(ctx context.Context, userID int64) (authz.Matrix, error) {
+			if matrix, ok := MatrixFrom(ctx); ok {
+				return matrix, nil
+			}
+
+			res, err := client.GetUserPermissions(ctx, &proto.GetUserPermissionsRequest{UserId: userID})
+			if err != nil {
+				return nil, err
+			}
+			return res.GetMatrix(), nil
+		},
+		opts...,
+	)
+}
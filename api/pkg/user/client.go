@@ -1,9 +1,7 @@
 package user
 
 import (
-	"fmt"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
-	"github.com/alpha-omega-corp/cloud/core/types"
 	"github.com/uptrace/bunrouter"
 	"google.golang.org/grpc"
 	"net/http"
@@ -13,6 +11,10 @@ type Client interface {
 	Self() proto.UserServiceClient
 	Login(w http.ResponseWriter, req bunrouter.Request) error
 	Register(w http.ResponseWriter, req bunrouter.Request) error
+	Refresh(w http.ResponseWriter, req bunrouter.Request) error
+	Logout(w http.ResponseWriter, req bunrouter.Request) error
+	RevokeSessions(w http.ResponseWriter, req bunrouter.Request) error
+	GetKeys(w http.ResponseWriter, req bunrouter.Request) error
 	GetUsers(w http.ResponseWriter, req bunrouter.Request) error
 	CreateUser(w http.ResponseWriter, req bunrouter.Request) error
 	UpdateUser(w http.ResponseWriter, req bunrouter.Request) error
@@ -28,17 +30,12 @@ type Client interface {
 }
 
 type userClient struct {
-	Client
 	client proto.UserServiceClient
 }
 
-func NewClient(c *types.Config) Client {
-	conn, err := grpc.Dial(*c.Url, grpc.WithInsecure())
-
-	if err != nil {
-		fmt.Println("Could not connect:", err)
-	}
+var _ Client = (*userClient)(nil)
 
+func NewClient(conn grpc.ClientConnInterface) Client {
 	return &userClient{client: proto.NewUserServiceClient(conn)}
 }
 
@@ -51,6 +48,18 @@ func (svc *userClient) Login(w http.ResponseWriter, req bunrouter.Request) error
 func (svc *userClient) Register(w http.ResponseWriter, req bunrouter.Request) error {
 	return RegisterHandler(w, req, svc.client)
 }
+func (svc *userClient) Refresh(w http.ResponseWriter, req bunrouter.Request) error {
+	return RefreshHandler(w, req, svc.client)
+}
+func (svc *userClient) Logout(w http.ResponseWriter, req bunrouter.Request) error {
+	return LogoutHandler(w, req, svc.client)
+}
+func (svc *userClient) RevokeSessions(w http.ResponseWriter, req bunrouter.Request) error {
+	return RevokeSessionsHandler(w, req, svc.client)
+}
+func (svc *userClient) GetKeys(w http.ResponseWriter, req bunrouter.Request) error {
+	return GetKeysHandler(w, req, svc.client)
+}
 func (svc *userClient) GetUsers(w http.ResponseWriter, req bunrouter.Request) error {
 	return GetUsersHandler(w, req, svc.client)
 }
@@ -72,8 +81,8 @@ func (svc *userClient) GetUserPermissions(w http.ResponseWriter, req bunrouter.R
 func (svc *userClient) GetServicePermissions(w http.ResponseWriter, req bunrouter.Request) error {
 	return GetServicePermissionsHandler(w, req, svc.client)
 }
-func (svc *userClient) CreatePermission(w http.ResponseWriter, req bunrouter.Request) error {
-	return CreatePermissionHandler(w, req, svc.client)
+func (svc *userClient) CreateServicePermissions(w http.ResponseWriter, req bunrouter.Request) error {
+	return CreateServicePermissionsHandler(w, req, svc.client)
 }
 func (svc *userClient) GetServices(w http.ResponseWriter, req bunrouter.Request) error {
 	return GetServices(w, req, svc.client)
@@ -1,12 +1,13 @@
 package user
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/core/httputils"
+	"github.com/alpha-omega-corp/cloud/core/server"
 	"github.com/uptrace/bunrouter"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -18,10 +19,20 @@ type LoginRequestBody struct {
 }
 
 type RegisterRequestBody struct {
+	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
+type RefreshRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type LogoutRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+	All          bool   `json:"all"`
+}
+
 type CreateRoleRequestBody struct {
 	Name string `json:"name"`
 }
@@ -63,7 +74,7 @@ func LoginHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServ
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func RegisterHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -73,6 +84,7 @@ func RegisterHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserS
 	}
 
 	res, err := s.Register(req.Context(), &proto.RegisterRequest{
+		Username: data.Username,
 		Email:    data.Email,
 		Password: data.Password,
 	})
@@ -81,7 +93,7 @@ func RegisterHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserS
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func CreateRoleHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -98,7 +110,7 @@ func CreateRoleHandler(w http.ResponseWriter, req bunrouter.Request, s proto.Use
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func GetRolesHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -108,10 +120,10 @@ func GetRolesHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserS
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
-func CreatePermissionHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
+func CreateServicePermissionsHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
 	data := new(CreatePermissionsRequestBody)
 	if err := json.NewDecoder(req.Body).Decode(data); err != nil {
 		return err
@@ -129,7 +141,7 @@ func CreatePermissionHandler(w http.ResponseWriter, req bunrouter.Request, s pro
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func GetServices(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -138,7 +150,7 @@ func GetServices(w http.ResponseWriter, req bunrouter.Request, s proto.UserServi
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func GetServicePermissionsHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -155,7 +167,7 @@ func GetServicePermissionsHandler(w http.ResponseWriter, req bunrouter.Request,
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func GetUserPermissionsHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -172,7 +184,7 @@ func GetUserPermissionsHandler(w http.ResponseWriter, req bunrouter.Request, s p
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func GetUsersHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -182,13 +194,12 @@ func GetUsersHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserS
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func CreateUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
 	data := new(CreateUserRequestBody)
 
-	fmt.Print(req.Body)
 	if err := json.NewDecoder(req.Body).Decode(data); err != nil {
 		return err
 	}
@@ -202,7 +213,7 @@ func CreateUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.Use
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func UpdateUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -224,6 +235,73 @@ func UpdateUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.Use
 		return err
 	}
 
+	return httputils.Render(w, res)
+}
+
+func RefreshHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
+	data := new(RefreshRequestBody)
+	if err := json.NewDecoder(req.Body).Decode(data); err != nil {
+		return err
+	}
+
+	res, err := s.Refresh(req.Context(), &proto.RefreshRequest{
+		RefreshToken: data.RefreshToken,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return httputils.Render(w, res)
+}
+
+func LogoutHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
+	data := new(LogoutRequestBody)
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(data); err != nil {
+			return err
+		}
+	}
+
+	res, err := s.Logout(req.Context(), &proto.LogoutRequest{
+		Token:        server.AuthToken(req.Context()),
+		RefreshToken: data.RefreshToken,
+		All:          data.All,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return httputils.Render(w, res)
+}
+
+func RevokeSessionsHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
+	userId, err := strconv.ParseInt(req.Params().ByName("id"), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.RevokeSessions(req.Context(), &proto.RevokeSessionsRequest{UserId: userId})
+	if err != nil {
+		return err
+	}
+
+	return httputils.Render(w, res)
+}
+
+// GetKeysHandler publishes the JSON Web Key Set verifying the access tokens.
+func GetKeysHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
+	res, err := s.GetKeys(req.Context(), &proto.GetKeysRequest{})
+	if err != nil {
+		return err
+	}
+
+	if res.Keys == nil {
+		res.Keys = []*proto.Key{}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
 	return bunrouter.JSON(w, res)
 }
 
@@ -238,7 +316,7 @@ func DeleteUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.Use
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func AssignUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
@@ -256,11 +334,11 @@ func AssignUserHandler(w http.ResponseWriter, req bunrouter.Request, s proto.Use
 		return err
 	}
 
-	return bunrouter.JSON(w, res)
+	return httputils.Render(w, res)
 }
 
 func GetTestHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserServiceClient) error {
-	fmt.Println(req.Body)
+	ctx := req.Context()
 	config := clientv3.Config{
 		Endpoints:   []string{"etcd:2380"},
 		DialTimeout: 5 * time.Second,
@@ -268,18 +346,24 @@ func GetTestHandler(w http.ResponseWriter, req bunrouter.Request, s proto.UserSe
 
 	c, err := clientv3.New(config)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
+	defer c.Close()
 
-	res, err := c.Put(context.Background(), "toto", "hello")
+	res, err := c.Put(ctx, "toto", "hello")
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
+	slog.DebugContext(ctx, "test key written", "revision", res.Header.GetRevision())
 
-	fmt.Print(res)
-
-	r, err := c.Get(context.Background(), "toto")
-	fmt.Println(r.Kvs)
+	r, err := c.Get(ctx, "toto")
+	if err != nil {
+		return err
+	}
+	if len(r.Kvs) == 0 {
+		return httputils.NotFound("test key not found")
+	}
+	slog.DebugContext(ctx, "test key read", "revision", r.Header.GetRevision(), "count", r.Count)
 
 	return bunrouter.JSON(w, &proto.UpdateUserRequest{
 		Id:   http.StatusOK,
@@ -1,25 +1,44 @@
 package user
 
 import (
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/alpha-omega-corp/cloud/core/httputils"
 	_ "github.com/spf13/viper/remote"
 	"github.com/uptrace/bunrouter"
 )
 
-func RegisterClient(svc Client, r *bunrouter.Router) Client {
+// RegisterClient routes the user service. Login, registration, refresh and
+// the key set are public, every other route requires a bearer token accepted by verify
+// and the permission it is declared with.
+func RegisterClient(svc Client, verify httputils.Verifier, a *authz.Authorizer, r *bunrouter.Router) Client {
 	r.POST("/login", svc.Login)
 	r.POST("/register", svc.Register)
-	r.GET("/roles", svc.GetRoles)
-	r.POST("/role", svc.CreateRole)
-	r.GET("/users", svc.GetUsers)
-	r.POST("/user", svc.CreateUser)
-	r.PUT("/user/:id", svc.UpdateUser)
-	r.DELETE("/user/:id", svc.DeleteUser)
-	r.POST("/user/assign", svc.AssignUser)
-	r.GET("/user/:id/permissions", svc.GetUserPermissions)
-	r.GET("/services", svc.GetServices)
-	r.GET("/service/:serviceId/permissions", svc.GetServicePermissions)
-	r.POST("/service/permissions", svc.CreateServicePermissions)
-	r.GET("/user/test", svc.GetTest)
+	r.POST("/refresh", svc.Refresh)
+	r.GET("/.well-known/jwks.json", svc.GetKeys)
+
+	r.Use(httputils.Authenticate(verify)).WithGroup("", func(g *bunrouter.Group) {
+		read := g.Use(a.Require("user.read"))
+		write := g.Use(a.Require("user.write"))
+		manage := g.Use(a.Require("user.manage"))
+
+		g.POST("/logout", svc.Logout)
+
+		read.GET("/roles", svc.GetRoles)
+		manage.POST("/role", svc.CreateRole)
+		read.GET("/users", svc.GetUsers)
+		write.POST("/user", svc.CreateUser)
+		write.PUT("/user/:id", svc.UpdateUser)
+		manage.DELETE("/user/:id", svc.DeleteUser)
+		manage.POST("/user/assign", svc.AssignUser)
+		manage.DELETE("/user/:id/sessions", svc.RevokeSessions)
+		// Users may read their own permissions, the SelfPolicy of the user
+		// service decides.
+		g.GET("/user/:id/permissions", svc.GetUserPermissions)
+		read.GET("/services", svc.GetServices)
+		read.GET("/service/:serviceId/permissions", svc.GetServicePermissions)
+		manage.POST("/service/permissions", svc.CreateServicePermissions)
+		manage.GET("/user/test", svc.GetTest)
+	})
 
 	return svc
 }
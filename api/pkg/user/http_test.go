@@ -0,0 +1,86 @@
+package user
+
+import (
+	"github.com/alpha-omega-corp/cloud/app/user/cmd/migrations"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/handlers"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	"github.com/alpha-omega-corp/cloud/core/httputils"
+	harness "github.com/alpha-omega-corp/cloud/core/testing"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bunrouter"
+	"google.golang.org/grpc"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRouter routes the public user endpoints to a user service backed by
+// the harness database.
+func newRouter(t *testing.T) *bunrouter.Router {
+	t.Helper()
+
+	h := harness.New(t, func(db *bun.DB, grpc *grpc.Server) {
+		sessions := handlers.NewSessionStore(db, 0)
+		auth := utils.NewAuthWrapper("secret", utils.WithRevocations(sessions))
+		proto.RegisterUserServiceServer(grpc, pkg.NewServer(db, auth, sessions))
+	},
+		harness.WithMigrations(migrations.FS),
+		harness.WithModels(
+			(*models.UserToRole)(nil),
+			(*models.User)(nil),
+			(*models.Role)(nil),
+			(*models.Service)(nil),
+			(*models.Permission)(nil),
+		),
+	)
+
+	svc := NewClient(h.Conn)
+	r := bunrouter.New(bunrouter.WithMiddleware(httputils.ErrorHandler))
+	r.POST("/register", svc.Register)
+	r.POST("/login", svc.Login)
+
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	r := newRouter(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			name: "complete",
+			body: `{"username": "jane", "email": "jane@example.org", "password": "secret"}`,
+			want: http.StatusCreated,
+		},
+		{
+			name: "without username",
+			body: `{"email": "john@example.org", "password": "secret"}`,
+			want: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tt.body)))
+
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login",
+		strings.NewReader(`{"email": "jane@example.org", "password": "secret"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login after register: got status %d: %s", w.Code, w.Body)
+	}
+}
@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"github.com/alpha-omega-corp/cloud/app/docker/cmd/migrations"
 	"github.com/alpha-omega-corp/cloud/app/docker/pkg"
 	"github.com/alpha-omega-corp/cloud/app/docker/pkg/models"
 	"github.com/alpha-omega-corp/cloud/app/docker/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg/types"
+	userproto "github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
 	"github.com/alpha-omega-corp/cloud/core"
-	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/alpha-omega-corp/cloud/core/config"
+	"github.com/alpha-omega-corp/cloud/core/discovery"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/health"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	"github.com/alpha-omega-corp/cloud/core/security"
+	"github.com/alpha-omega-corp/cloud/core/server"
+	st "github.com/alpha-omega-corp/cloud/core/types"
 	"github.com/docker/docker/client"
 	"github.com/uptrace/bun"
 	"google.golang.org/grpc"
@@ -18,22 +30,86 @@ var (
 )
 
 func main() {
-	core.NewApp(embedFS, "docker").
-		CreateApp(func(config *types.Config, db *bun.DB, grpc *grpc.Server) {
-			dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cfg := new(types.Config)
+
+	// Callers are identified and authorized by the user service.
+	var users userproto.UserServiceClient
+	authorizer := authz.NewAuthorizer(
+		func(ctx context.Context) (int64, error) {
+			token := server.AuthToken(ctx)
+			if token == "" {
+				return 0, grpcutils.Unauthenticated("missing bearer token")
+			}
+
+			res, err := users.Validate(ctx, &userproto.ValidateRequest{Token: token})
 			if err != nil {
-				panic(err)
+				return 0, err
 			}
+			return res.GetUser().GetId(), nil
+		},
+		func(ctx context.Context, userID int64) (authz.Matrix, error) {
+			res, err := users.GetUserPermissions(ctx, &userproto.GetUserPermissionsRequest{UserId: userID})
+			if err != nil {
+				return nil, err
+			}
+			return res.GetMatrix(), nil
+		},
+	)
+
+	app := core.NewApp(embedFS, "docker").
+		WithMigrations(migrations.Migrations).
+		WithConfig(cfg).
+		WithInterceptors(authorizer.Interceptors(pkg.Policy))
+	app.CreateApp(func(c *st.Config, db *bun.DB, grpc *grpc.Server) {
+		users = dialUsers(app, c)
+
+		dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			panic(err)
+		}
+
+		app.Health().Add("docker", func(ctx context.Context) error {
+			_, err := dockerClient.Ping(ctx)
+			return err
+		})
+		app.Lifecycle().Append(core.Hook{
+			Name: "docker",
+			OnStop: func(ctx context.Context) error {
+				return dockerClient.Close()
+			},
+		})
+
+		configRef := st.NewConfigRef(cfg)
+		config.WatchAs(app.ConfigHandler(), "docker", configRef.Store)
+
+		proto.RegisterDockerServiceServer(grpc, pkg.NewServer(configRef, dockerClient, db))
+	}, []interface{}{
+		(*models.Dockerfile)(nil),
+	}...)
+}
+
+func dialUsers(app *core.App, config *st.Config) userproto.UserServiceClient {
+	creds, err := security.ClientCredentials(config.TLS)
+	if err != nil {
+		logging.Fatal("user client error", "error", err)
+	}
+
+	conn, err := discovery.Dial(app.ConfigHandler().Client(), "user", config.Services["user"],
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(server.PropagateUnary()),
+		grpc.WithChainStreamInterceptor(server.PropagateStream()),
+	)
+	if err != nil {
+		logging.Fatal("user client error", "error", err)
+	}
+
+	app.Health().Add("user", health.GRPC(conn))
+	app.Lifecycle().Append(core.Hook{
+		Name: "user client",
+		OnStop: func(ctx context.Context) error {
+			return conn.Close()
+		},
+	})
 
-			defer func(cli *client.Client) {
-				err := cli.Close()
-				if err != nil {
-					panic(err)
-				}
-			}(dockerClient)
-
-			proto.RegisterDockerServiceServer(grpc, pkg.NewServer(config, dockerClient, db))
-		}, []interface{}{
-			(*models.Dockerfile)(nil),
-		}...)
+	return userproto.NewUserServiceClient(conn)
 }
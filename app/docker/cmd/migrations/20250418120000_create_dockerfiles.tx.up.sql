@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "dockerfiles" (
+    "id"         BIGSERIAL   NOT NULL,
+    "name"       VARCHAR,
+    "content"    BYTEA,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    UNIQUE ("name")
+);
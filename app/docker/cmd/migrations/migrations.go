@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"embed"
+	"github.com/uptrace/bun/migrate"
+)
+
+var Migrations = migrate.NewMigrations()
+
+// FS holds the SQL migrations, for the test harness to apply them as well.
+//
+//go:embed *.sql
+var FS embed.FS
+
+func init() {
+	if err := Migrations.Discover(FS); err != nil {
+		panic(err)
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg/types"
+	st "github.com/alpha-omega-corp/cloud/core/types"
 	docker "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
@@ -31,10 +32,10 @@ type containerHandler struct {
 	ContainerHandler
 
 	client *client.Client
-	config types.Config
+	config *st.ConfigRef[types.Config]
 }
 
-func NewContainerHandler(cli *client.Client, c types.Config) ContainerHandler {
+func NewContainerHandler(cli *client.Client, c *st.ConfigRef[types.Config]) ContainerHandler {
 	return &containerHandler{
 		client: cli,
 		config: c,
@@ -42,17 +43,26 @@ func NewContainerHandler(cli *client.Client, c types.Config) ContainerHandler {
 }
 
 func (h *containerHandler) Start(ctx context.Context, cId string) error {
-	return h.client.ContainerStart(ctx, cId, container.StartOptions{})
+	err := h.client.ContainerStart(ctx, cId, container.StartOptions{})
+	containerOperations.WithLabelValues("start", result(err)).Inc()
+
+	return err
 }
 
 func (h *containerHandler) Stop(ctx context.Context, cId string) error {
-	return h.client.ContainerStop(ctx, cId, container.StopOptions{})
+	err := h.client.ContainerStop(ctx, cId, container.StopOptions{})
+	containerOperations.WithLabelValues("stop", result(err)).Inc()
+
+	return err
 }
 
 func (h *containerHandler) Delete(ctx context.Context, cId string) error {
-	return h.client.ContainerRemove(ctx, cId, container.RemoveOptions{
+	err := h.client.ContainerRemove(ctx, cId, container.RemoveOptions{
 		Force: true,
 	})
+	containerOperations.WithLabelValues("delete", result(err)).Inc()
+
+	return err
 }
 
 func (h *containerHandler) GetAll(ctx context.Context) ([]docker.Container, error) {
@@ -83,7 +93,7 @@ func (h *containerHandler) GetLogs(containerId string, ctx context.Context) (io.
 func (h *containerHandler) PullImage(imgName string, ctx context.Context) error {
 	authConfig := registry.AuthConfig{
 		Username: "packages",
-		Password: h.config.Env.GetString("token"),
+		Password: h.config.Load().Token.Value(),
 	}
 
 	encodedJSON, err := json.Marshal(authConfig)
@@ -117,6 +127,7 @@ func (h *containerHandler) CreateFrom(ctx context.Context, path string, name str
 	resp, err := h.client.ContainerCreate(ctx, &container.Config{
 		Image: imgName,
 	}, nil, nil, nil, name)
+	containerOperations.WithLabelValues("create", result(err)).Inc()
 	if err != nil {
 		panic(err)
 	}
@@ -129,5 +140,6 @@ func (h *containerHandler) CreateFrom(ctx context.Context, path string, name str
 }
 
 func (h *containerHandler) imageName(path string) string {
-	return h.config.Env.GetString("registry") + "/" + h.config.Env.GetString("name") + "/" + strings.Replace(path, "/", ":", 1)
+	config := h.config.Load()
+	return config.Registry + "/" + config.Name + "/" + strings.Replace(path, "/", ":", 1)
 }
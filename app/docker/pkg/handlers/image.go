@@ -5,7 +5,8 @@ import (
 	"context"
 	"github.com/alpha-omega-corp/cloud/app/docker/pkg/models"
 	"github.com/alpha-omega-corp/cloud/app/docker/pkg/proto"
-	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg/types"
+	st "github.com/alpha-omega-corp/cloud/core/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
@@ -23,13 +24,13 @@ type ImageService interface {
 
 type imageService struct {
 	ImageService
-	config   *types.Config
+	config   *st.ConfigRef[types.Config]
 	client   *client.Client
 	template TemplateHandler
-	db       *bun.DB
+	db       bun.IDB
 }
 
-func NewImageService(config *types.Config, client *client.Client, db *bun.DB) ImageService {
+func NewImageService(config *st.ConfigRef[types.Config], client *client.Client, db bun.IDB) ImageService {
 	return &imageService{
 		db:       db,
 		client:   client,
@@ -38,9 +39,11 @@ func NewImageService(config *types.Config, client *client.Client, db *bun.DB) Im
 	}
 }
 
-func (s *imageService) GetImage(ctx context.Context, req *proto.GetImageRequest) (*proto.GetImageResponse, error) {
+func (s *imageService) GetImage(ctx context.Context, req *proto.GetImageRequest) (res *proto.GetImageResponse, err error) {
+	defer func() { imageOperations.WithLabelValues("get", result(err)).Inc() }()
+
 	dockerfile := new(models.Dockerfile)
-	err := s.db.NewSelect().Model(dockerfile).Where("name = ?", req.Name).Scan(ctx)
+	err = s.db.NewSelect().Model(dockerfile).Where("name = ?", req.Name).Scan(ctx)
 	if err != nil {
 		return &proto.GetImageResponse{
 			Status:     http.StatusNoContent,
@@ -50,7 +53,7 @@ func (s *imageService) GetImage(ctx context.Context, req *proto.GetImageRequest)
 	}
 
 	images, err := s.client.ImageList(ctx, image.ListOptions{
-		Filters: filters.NewArgs(filters.Arg("reference", s.config.Env.GetString("organization")+"/"+req.Name)),
+		Filters: filters.NewArgs(filters.Arg("reference", s.config.Load().Organization+"/"+req.Name)),
 	})
 	if err != nil {
 		return nil, err
@@ -74,31 +77,25 @@ func (s *imageService) GetImage(ctx context.Context, req *proto.GetImageRequest)
 	}, nil
 }
 
-func (s *imageService) StoreImage(ctx context.Context, req *proto.StoreImageRequest) (*proto.StoreImageResponse, error) {
-	dockerfile := new(models.Dockerfile)
-	err := s.db.NewSelect().Model(dockerfile).Where("name = ?", req.Name).Scan(ctx)
+func (s *imageService) StoreImage(ctx context.Context, req *proto.StoreImageRequest) (res *proto.StoreImageResponse, err error) {
+	defer func() { imageOperations.WithLabelValues("store", result(err)).Inc() }()
 
 	content := bytes.Trim(req.Content, "\x00")
 
-	if err != nil {
-		_, err = s.db.NewInsert().Model(&models.Dockerfile{
+	// A single upsert, so that concurrent stores of a new name cannot both
+	// try to insert it.
+	_, err = s.db.
+		NewInsert().
+		Model(&models.Dockerfile{
 			Name:    req.Name,
 			Content: content,
-		}).Exec(ctx)
-
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		_, err = s.db.
-			NewUpdate().
-			Model(dockerfile).
-			Set("content = ?", content).
-			Where("name = ?", req.Name).
-			Exec(ctx)
-		if err != nil {
-			return nil, err
-		}
+		}).
+		On("CONFLICT (name) DO UPDATE").
+		Set("content = EXCLUDED.content").
+		Set("updated_at = current_timestamp").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	return &proto.StoreImageResponse{
@@ -106,8 +103,10 @@ func (s *imageService) StoreImage(ctx context.Context, req *proto.StoreImageRequ
 	}, nil
 }
 
-func (s *imageService) BuildImage(ctx context.Context, req *proto.BuildImageRequest) (*proto.BuildImageResponse, error) {
-	path := s.config.Env.GetString("build_path") + req.Name
+func (s *imageService) BuildImage(ctx context.Context, req *proto.BuildImageRequest) (res *proto.BuildImageResponse, err error) {
+	defer func() { buildsTotal.WithLabelValues(result(err)).Inc() }()
+
+	path := s.config.Load().BuildPath + req.Name
 	tag := "latest"
 
 	makeFile, err := s.template.CreateDockerBuild(req.Name, tag)
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/alpha-omega-corp/cloud/core/metrics"
+)
+
+var (
+	buildsTotal         = metrics.Counter("docker_builds_total", "Docker image builds by result.", "result")
+	imageOperations     = metrics.Counter("docker_image_operations_total", "Docker image operations by operation and result.", "operation", "result")
+	containerOperations = metrics.Counter("docker_container_operations_total", "Docker container operations by operation and result.", "operation", "result")
+)
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
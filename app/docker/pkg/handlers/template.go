@@ -26,10 +26,10 @@ type TemplateHandler interface {
 type templateHandler struct {
 	TemplateHandler
 	template *template.Template
-	config   *st.Config
+	config   *st.ConfigRef[types.Config]
 }
 
-func NewTemplateHandler(config *st.Config) TemplateHandler {
+func NewTemplateHandler(config *st.ConfigRef[types.Config]) TemplateHandler {
 	fileSys := getFS()
 	tmpl, err := template.ParseFS(fileSys, "*.template")
 	if err != nil {
@@ -60,7 +60,7 @@ func (h *templateHandler) CreateDockerBuild(name string, tag string) (*bytes.Buf
 	if err := h.template.ExecuteTemplate(buf, "makefile.template", &types.CreateDockerBuildDto{
 		Name: name,
 		Tag:  tag,
-		Org:  h.config.Env.GetString("organization"),
+		Org:  h.config.Load().Organization,
 	}); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,17 @@
+package pkg
+
+import (
+	proto2 "github.com/alpha-omega-corp/cloud/app/docker/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+)
+
+// Policy lists the permission required by each RPC.
+var Policy = authz.Policy{
+	method("GetImage"):   "docker.read",
+	method("StoreImage"): "docker.write",
+	method("BuildImage"): "docker.write",
+}
+
+func method(name string) string {
+	return authz.Method(proto2.DockerService_ServiceDesc, name)
+}
@@ -0,0 +1,157 @@
+package pkg_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alpha-omega-corp/cloud/app/docker/cmd/migrations"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg/models"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/docker/pkg/types"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/server"
+	harness "github.com/alpha-omega-corp/cloud/core/testing"
+	st "github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/docker/docker/client"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Bearer tokens of the test callers, mapped to their user IDs.
+const (
+	readerToken = "reader"
+	writerToken = "writer"
+)
+
+var matrices = map[int64]authz.Matrix{
+	1: {"docker.read": true},
+	2: {"docker.read": true, "docker.write": true},
+}
+
+// newDaemon fakes the Docker Engine API, listing a single image.
+func newDaemon(t *testing.T) *client.Client {
+	t.Helper()
+
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasSuffix(req.URL.Path, "/images/json") {
+			http.NotFound(w, req)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode([]map[string]any{{
+			"Id":       "sha256:web",
+			"RepoTags": []string{"acme/web:latest"},
+			"Size":     42,
+			"Created":  1700000000,
+		}})
+	}))
+	t.Cleanup(daemon.Close)
+
+	cli, err := client.NewClientWithOpts(
+		client.WithHost("tcp://"+strings.TrimPrefix(daemon.URL, "http://")),
+		client.WithVersion("1.45"),
+	)
+	if err != nil {
+		t.Fatalf("docker client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cli.Close()
+	})
+
+	return cli
+}
+
+func newClient(t *testing.T) proto.DockerServiceClient {
+	t.Helper()
+
+	authorizer := authz.NewAuthorizer(
+		func(ctx context.Context) (int64, error) {
+			switch server.AuthToken(ctx) {
+			case readerToken:
+				return 1, nil
+			case writerToken:
+				return 2, nil
+			}
+			return 0, grpcutils.Unauthenticated("unknown token")
+		},
+		func(_ context.Context, userID int64) (authz.Matrix, error) {
+			return matrices[userID], nil
+		},
+	)
+	unary, stream := authorizer.Interceptors(pkg.Policy)
+
+	cli := newDaemon(t)
+	config := st.NewConfigRef(&types.Config{Organization: "acme", BuildPath: t.TempDir() + "/"})
+
+	h := harness.New(t, func(db *bun.DB, grpc *grpc.Server) {
+		proto.RegisterDockerServiceServer(grpc, pkg.NewServer(config, cli, db))
+	},
+		harness.WithMigrations(migrations.FS),
+		harness.WithModels((*models.Dockerfile)(nil)),
+		harness.WithServerOptions(
+			server.WithUnaryInterceptors(unary...),
+			server.WithStreamInterceptors(stream...),
+		),
+	)
+
+	return proto.NewDockerServiceClient(h.Conn)
+}
+
+func as(token string) context.Context {
+	return server.WithAuthToken(context.Background(), token)
+}
+
+func TestStoreImage(t *testing.T) {
+	c := newClient(t)
+
+	for _, content := range []string{"FROM alpine\n", "FROM debian\n"} {
+		if _, err := c.StoreImage(as(writerToken), &proto.StoreImageRequest{Name: "web", Content: []byte(content)}); err != nil {
+			t.Fatalf("store image: %v", err)
+		}
+
+		res, err := c.GetImage(as(readerToken), &proto.GetImageRequest{Name: "web"})
+		if err != nil {
+			t.Fatalf("get image: %v", err)
+		}
+		if string(res.GetDockerfile()) != content {
+			t.Fatalf("got dockerfile %q, want %q", res.GetDockerfile(), content)
+		}
+		if res.GetImage().GetId() != "sha256:web" {
+			t.Fatalf("got image %v, want sha256:web", res.GetImage())
+		}
+	}
+}
+
+func TestGetImageMissing(t *testing.T) {
+	c := newClient(t)
+
+	res, err := c.GetImage(as(readerToken), &proto.GetImageRequest{Name: "missing"})
+	if err != nil {
+		t.Fatalf("get image: %v", err)
+	}
+	if res.GetStatus() != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", res.GetStatus(), http.StatusNoContent)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	c := newClient(t)
+	req := &proto.StoreImageRequest{Name: "web", Content: []byte("FROM alpine\n")}
+
+	_, err := c.StoreImage(context.Background(), req)
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("anonymous store: got %s, want Unauthenticated", code)
+	}
+
+	_, err = c.StoreImage(as(readerToken), req)
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Fatalf("reader store: got %s, want PermissionDenied", code)
+	}
+}
@@ -0,0 +1,11 @@
+package types
+
+import st "github.com/alpha-omega-corp/cloud/core/types"
+
+type Config struct {
+	Organization string    `mapstructure:"organization" validate:"required"`
+	BuildPath    string    `mapstructure:"build_path" default:"/tmp/"`
+	Registry     string    `mapstructure:"registry"`
+	Name         string    `mapstructure:"name"`
+	Token        st.Secret `mapstructure:"token"`
+}
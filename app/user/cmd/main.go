@@ -2,15 +2,21 @@ package main
 
 import (
 	"embed"
+	"github.com/alpha-omega-corp/cloud/app/user/cmd/migrations"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/handlers"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/types"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
 	"github.com/alpha-omega-corp/cloud/core"
-	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/alpha-omega-corp/cloud/core/config"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	st "github.com/alpha-omega-corp/cloud/core/types"
 	_ "github.com/spf13/viper/remote"
 	"github.com/uptrace/bun"
 	"google.golang.org/grpc"
+	"log/slog"
 )
 
 var (
@@ -19,15 +25,68 @@ var (
 )
 
 func main() {
-	core.NewApp(embedFS, "user").
-		CreateApp(func(config *types.Config, db *bun.DB, grpc *grpc.Server) {
-			auth := utils.NewAuthWrapper(config.Env.GetString("secret"))
-			proto.RegisterUserServiceServer(grpc, pkg.NewServer(db, auth))
-		}, []interface{}{
-			(*models.UserToRole)(nil),
-			(*models.User)(nil),
-			(*models.Role)(nil),
-			(*models.Service)(nil),
-			(*models.Permission)(nil),
-		}...)
+	cfg := new(types.Config)
+
+	var (
+		auth  *utils.AuthWrapper
+		perms handlers.PermService
+	)
+	authorizer := pkg.NewAuthorizer(
+		func() *utils.AuthWrapper { return auth },
+		func() handlers.PermService { return perms },
+	)
+	unary, stream := pkg.Interceptors(authorizer)
+
+	app := core.NewApp(embedFS, "user").
+		WithMigrations(migrations.Migrations).
+		WithConfig(cfg).
+		WithInterceptors(unary, stream)
+	app.CreateApp(func(c *st.Config, db *bun.DB, grpc *grpc.Server) {
+		if c.Authz != nil {
+			authorizer.SetTrustedServices(c.Authz.TrustedServices...)
+		}
+
+		keys, err := cfg.KeySet()
+		if err != nil {
+			logging.Fatal("invalid signing keys", "error", err)
+		}
+
+		sessions := handlers.NewSessionStore(db, cfg.RefreshTTL)
+		auth = utils.NewAuthWrapper(cfg.Secret.Value(),
+			utils.WithExpiry(cfg.AccessTTL),
+			utils.WithIssuer(cfg.Issuer),
+			utils.WithAudience(cfg.Audience),
+			utils.WithLeeway(cfg.ClockSkew),
+			utils.WithRevocations(sessions),
+		)
+		auth.Update(utils.Signing{
+			Secret:        cfg.Secret.Value(),
+			Keys:          keys,
+			HMACMigration: cfg.HMACMigration,
+		})
+		perms = handlers.NewPermService(db)
+		config.WatchAs(app.ConfigHandler(), "user", func(c *types.Config) {
+			keys, err := c.KeySet()
+			if err != nil {
+				slog.Error("invalid signing keys", "error", err)
+				return
+			}
+
+			auth.Update(utils.Signing{
+				Secret:        c.Secret.Value(),
+				Keys:          keys,
+				HMACMigration: c.HMACMigration,
+			})
+		})
+
+		proto.RegisterUserServiceServer(grpc, pkg.NewServer(db, auth, sessions))
+	}, []interface{}{
+		(*models.UserToRole)(nil),
+		(*models.RefreshToken)(nil),
+		(*models.RevokedToken)(nil),
+		(*models.User)(nil),
+		(*models.Role)(nil),
+		(*models.Service)(nil),
+		(*models.Permission)(nil),
+	}...)
 }
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS "user_to_roles";
+
+--bun:split
+
+DROP TABLE IF EXISTS "permissions";
+
+--bun:split
+
+DROP TABLE IF EXISTS "services";
+
+--bun:split
+
+DROP TABLE IF EXISTS "roles";
+
+--bun:split
+
+DROP TABLE IF EXISTS "users";
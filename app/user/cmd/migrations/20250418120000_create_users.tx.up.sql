@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS "users" (
+    "id"                 BIGSERIAL   NOT NULL,
+    "name"               VARCHAR,
+    "email"              VARCHAR,
+    "encrypted_password" VARCHAR,
+    "created_at"         TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    "updated_at"         TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    UNIQUE ("email")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id"   BIGSERIAL NOT NULL,
+    "name" VARCHAR,
+    PRIMARY KEY ("id"),
+    UNIQUE ("name")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "services" (
+    "id"   BIGSERIAL NOT NULL,
+    "name" VARCHAR,
+    PRIMARY KEY ("id"),
+    UNIQUE ("name")
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+    "id"         BIGSERIAL NOT NULL,
+    "read"       BOOLEAN,
+    "write"      BOOLEAN,
+    "manage"     BOOLEAN,
+    "role_id"    BIGINT,
+    "service_id" BIGINT,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("service_id") REFERENCES "services" ("id") ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "user_to_roles" (
+    "user_id" BIGINT NOT NULL,
+    "role_id" BIGINT NOT NULL,
+    PRIMARY KEY ("user_id", "role_id"),
+    FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE,
+    FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "revoked_tokens";
+
+--bun:split
+
+DROP TABLE IF EXISTS "refresh_tokens";
+
+--bun:split
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "session_generation";
@@ -0,0 +1,23 @@
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "session_generation" BIGINT NOT NULL DEFAULT 0;
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+    "id"         BIGSERIAL   NOT NULL,
+    "user_id"    BIGINT      NOT NULL,
+    "hash"       VARCHAR     NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    "revoked_at" TIMESTAMPTZ,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    UNIQUE ("hash"),
+    FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+    "jti"        VARCHAR     NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("jti")
+);
@@ -2,32 +2,49 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
 	"github.com/uptrace/bun"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"net/http"
+	"net/mail"
 )
 
 type AuthService interface {
 	Login(ctx context.Context, req *proto.LoginRequest) (*proto.LoginResponse, error)
 	Register(ctx context.Context, req *proto.RegisterRequest) (*proto.RegisterResponse, error)
 	Validate(ctx context.Context, req *proto.ValidateRequest) (*proto.ValidateResponse, error)
+	Refresh(ctx context.Context, req *proto.RefreshRequest) (*proto.RefreshResponse, error)
+	Logout(ctx context.Context, req *proto.LogoutRequest) (*proto.LogoutResponse, error)
+	RevokeSessions(ctx context.Context, req *proto.RevokeSessionsRequest) (*proto.RevokeSessionsResponse, error)
+	GetKeys(ctx context.Context) (*proto.GetKeysResponse, error)
 }
 
 type authService struct {
-	auth *utils.AuthWrapper
-	db   *bun.DB
+	auth     *utils.AuthWrapper
+	sessions *SessionStore
+	perms    PermService
+	db       bun.IDB
 }
 
-func NewAuthService(w *utils.AuthWrapper, db *bun.DB) AuthService {
+func NewAuthService(w *utils.AuthWrapper, sessions *SessionStore, db bun.IDB) AuthService {
 	return &authService{
-		auth: w,
-		db:   db,
+		auth:     w,
+		sessions: sessions,
+		perms:    NewPermService(db),
+		db:       db,
 	}
 }
 
 func (s *authService) Register(ctx context.Context, req *proto.RegisterRequest) (*proto.RegisterResponse, error) {
+	if violations := validateRegister(req); len(violations) > 0 {
+		return nil, grpcutils.InvalidArgument("invalid registration", violations...)
+	}
+
 	_, err := s.db.NewInsert().Model(&models.User{
 		Name:     req.Username,
 		Email:    req.Email,
@@ -43,6 +60,26 @@ func (s *authService) Register(ctx context.Context, req *proto.RegisterRequest)
 	}, nil
 }
 
+func validateRegister(req *proto.RegisterRequest) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	if req.Email == "" {
+		violations = append(violations, grpcutils.FieldViolation("email", "is required"))
+	} else if _, err := mail.ParseAddress(req.Email); err != nil {
+		violations = append(violations, grpcutils.FieldViolation("email", "is not a valid email address"))
+	}
+
+	if req.Username == "" {
+		violations = append(violations, grpcutils.FieldViolation("username", "is required"))
+	}
+
+	if req.Password == "" {
+		violations = append(violations, grpcutils.FieldViolation("password", "is required"))
+	}
+
+	return violations
+}
+
 func (s *authService) Login(ctx context.Context, req *proto.LoginRequest) (*proto.LoginResponse, error) {
 	var user models.User
 	err := s.db.
@@ -51,6 +88,10 @@ func (s *authService) Login(ctx context.Context, req *proto.LoginRequest) (*prot
 		Where("email = ?", req.Email).
 		Scan(ctx, &user)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, grpcutils.Unauthenticated("invalid credentials")
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -58,17 +99,23 @@ func (s *authService) Login(ctx context.Context, req *proto.LoginRequest) (*prot
 	match := utils.CheckPasswordHash(req.Password, user.Password)
 
 	if !match {
-		return &proto.LoginResponse{
-			Status: http.StatusNotFound,
-			Error:  "User not found",
-		}, nil
+		return nil, grpcutils.Unauthenticated("invalid credentials")
+	}
+
+	token, err := s.generateToken(ctx, &user)
+	if err != nil {
+		return nil, err
 	}
 
-	token, _ := s.auth.GenerateToken(user)
+	refreshToken, err := s.sessions.Create(ctx, s.db, user.Id)
+	if err != nil {
+		return nil, err
+	}
 
 	return &proto.LoginResponse{
-		Status: http.StatusOK,
-		Token:  token,
+		Status:       http.StatusOK,
+		Token:        token,
+		RefreshToken: refreshToken,
 		User: &proto.User{
 			Id:    user.Id,
 			Email: user.Email,
@@ -77,22 +124,19 @@ func (s *authService) Login(ctx context.Context, req *proto.LoginRequest) (*prot
 }
 
 func (s *authService) Validate(ctx context.Context, req *proto.ValidateRequest) (*proto.ValidateResponse, error) {
-	claims, err := s.auth.ValidateToken(req.Token)
-
+	claims, err := s.validateToken(ctx, req.Token)
 	if err != nil {
-		return &proto.ValidateResponse{
-			Status: http.StatusForbidden,
-			Error:  err.Error(),
-		}, nil
+		return nil, err
 	}
 
 	var user models.User
 	err = s.db.NewSelect().Model(&user).Where("email = ?", claims.Email).Scan(ctx, &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, grpcutils.Unauthenticated("invalid credentials")
+	}
+
 	if err != nil {
-		return &proto.ValidateResponse{
-			Status: http.StatusForbidden,
-			Error:  "Invalid Credentials",
-		}, nil
+		return nil, err
 	}
 
 	return &proto.ValidateResponse{
@@ -103,3 +147,104 @@ func (s *authService) Validate(ctx context.Context, req *proto.ValidateRequest)
 		},
 	}, nil
 }
+
+func (s *authService) Refresh(ctx context.Context, req *proto.RefreshRequest) (*proto.RefreshResponse, error) {
+	user, refreshToken, err := s.sessions.Rotate(ctx, req.RefreshToken)
+	if errors.Is(err, errRefreshInvalid) || errors.Is(err, errRefreshReused) {
+		return nil, grpcutils.Unauthenticated("%v", err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.generateToken(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.RefreshResponse{
+		Status:       http.StatusOK,
+		Token:        token,
+		RefreshToken: refreshToken,
+		User: &proto.User{
+			Id:    user.Id,
+			Email: user.Email,
+		},
+	}, nil
+}
+
+// Logout revokes the access token and the refresh token of the session, or
+// every session of the user with all set.
+func (s *authService) Logout(ctx context.Context, req *proto.LogoutRequest) (*proto.LogoutResponse, error) {
+	claims, err := s.validateToken(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.All {
+		err = s.sessions.RevokeAll(ctx, claims.UserId)
+	} else {
+		err = s.sessions.RevokeAccess(ctx, claims)
+		if err == nil && req.RefreshToken != "" {
+			err = s.sessions.Revoke(ctx, claims.UserId, req.RefreshToken)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.LogoutResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *authService) RevokeSessions(ctx context.Context, req *proto.RevokeSessionsRequest) (*proto.RevokeSessionsResponse, error) {
+	if err := s.sessions.RevokeAll(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	return &proto.RevokeSessionsResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// GetKeys publishes the public keys verifying the access tokens. The set is
+// empty when tokens are signed with the HMAC secret.
+func (s *authService) GetKeys(ctx context.Context) (*proto.GetKeysResponse, error) {
+	keys := s.auth.Keys()
+	if keys == nil {
+		return &proto.GetKeysResponse{}, nil
+	}
+
+	return keys.JWKS(), nil
+}
+
+// generateToken issues an access token carrying the roles and permissions
+// the user currently holds.
+func (s *authService) generateToken(ctx context.Context, user *models.User) (string, error) {
+	if err := s.db.NewSelect().
+		Model(user).
+		Relation("Roles").
+		WherePK().
+		Scan(ctx); err != nil {
+		return "", err
+	}
+
+	res, err := s.perms.GetUserPermissions(ctx, &proto.GetUserPermissionsRequest{UserId: user.Id})
+	if err != nil {
+		return "", err
+	}
+
+	return s.auth.GenerateToken(*user, res.GetMatrix())
+}
+
+func (s *authService) validateToken(ctx context.Context, token string) (*utils.AuthClaims, error) {
+	claims, err := s.auth.ValidateToken(ctx, token)
+	if errors.Is(err, utils.ErrInvalidToken) {
+		return nil, grpcutils.Unauthenticated("%v", err)
+	}
+
+	return claims, err
+}
@@ -15,10 +15,10 @@ type RoleService interface {
 
 type roleService struct {
 	RoleService
-	db *bun.DB
+	db bun.IDB
 }
 
-func NewRoleService(db *bun.DB) RoleService {
+func NewRoleService(db bun.IDB) RoleService {
 	return &roleService{
 		db: db,
 	}
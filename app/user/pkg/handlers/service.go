@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
 	"github.com/uptrace/bun"
 	"net/http"
 	"strings"
@@ -18,10 +21,10 @@ type PermService interface {
 }
 
 type permService struct {
-	db *bun.DB
+	db bun.IDB
 }
 
-func NewPermService(db *bun.DB) PermService {
+func NewPermService(db bun.IDB) PermService {
 	return &permService{
 		db: db,
 	}
@@ -71,7 +74,9 @@ func (s *permService) GetServicePermissions(ctx context.Context, req *proto.GetS
 		Model(&service).
 		Relation("Permissions").
 		Where("id = ?", req.ServiceId).
-		Scan(ctx); err != nil {
+		Scan(ctx); errors.Is(err, sql.ErrNoRows) {
+		return nil, grpcutils.NotFound("service %d not found", req.ServiceId)
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -112,7 +117,9 @@ func (s *permService) GetUserPermissions(ctx context.Context, req *proto.GetUser
 		Model(user).
 		Relation("Roles").
 		Where("id = ?", req.UserId).
-		Scan(ctx); err != nil {
+		Scan(ctx); errors.Is(err, sql.ErrNoRows) {
+		return nil, grpcutils.NotFound("user %d not found", req.UserId)
+	} else if err != nil {
 		return nil, err
 	}
 
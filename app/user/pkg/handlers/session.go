@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	"github.com/alpha-omega-corp/cloud/core/database"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const DefaultRefreshTTL = 30 * 24 * time.Hour
+
+var (
+	errRefreshInvalid = errors.New("invalid refresh token")
+	errRefreshReused  = errors.New("refresh token reused, every session was revoked")
+)
+
+// SessionStore keeps the refresh tokens and the revoked access tokens.
+type SessionStore struct {
+	db  bun.IDB
+	ttl time.Duration
+}
+
+var _ utils.Revocations = (*SessionStore)(nil)
+
+func NewSessionStore(db bun.IDB, ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = DefaultRefreshTTL
+	}
+
+	return &SessionStore{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// Revoked reports the tokens revoked by jti, issued before the sessions of
+// the user were revoked, as told by their generation, or belonging to a
+// deleted user. It reads from the primary, a lagging replica would accept
+// tokens that were just revoked.
+func (s *SessionStore) Revoked(ctx context.Context, claims *utils.AuthClaims) (bool, error) {
+	primary := database.Primary(s.db)
+
+	exists, err := s.db.NewSelect().
+		Conn(primary).
+		Model((*models.RevokedToken)(nil)).
+		Where("jti = ?", claims.ID).
+		Exists(ctx)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	user := new(models.User)
+	err = s.db.NewSelect().
+		Conn(primary).
+		Model(user).
+		Column("session_generation").
+		Where("id = ?", claims.UserId).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return claims.Generation < user.SessionGeneration, nil
+}
+
+// Create starts a session for the user and returns its refresh token.
+func (s *SessionStore) Create(ctx context.Context, db bun.IDB, userID int64) (string, error) {
+	token, hash, err := utils.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.NewInsert().Model(&models.RefreshToken{
+		UserId:    userID,
+		Hash:      hash,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}).Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Rotate revokes the refresh token and returns the user it belongs to along
+// with a new refresh token. Presenting a revoked token revokes every session
+// of its user, as it was likely stolen. Its reads run in the transaction, on
+// the primary.
+func (s *SessionStore) Rotate(ctx context.Context, token string) (user *models.User, refreshToken string, err error) {
+	reused := false
+
+	err = database.RunInTx(ctx, s.db, nil, func(tx bun.IDB) error {
+		session := new(models.RefreshToken)
+		err := tx.NewSelect().
+			Model(session).
+			Where("hash = ?", utils.HashRefreshToken(token)).
+			Apply(database.ForUpdate).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errRefreshInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if !session.RevokedAt.IsZero() {
+			reused = true
+			return s.revokeAll(ctx, tx, session.UserId)
+		}
+
+		if time.Now().After(session.ExpiresAt) {
+			return errRefreshInvalid
+		}
+
+		session.RevokedAt = time.Now()
+		if _, err := tx.NewUpdate().Model(session).Column("revoked_at").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+
+		user = new(models.User)
+		if err := tx.NewSelect().Model(user).Where("id = ?", session.UserId).Scan(ctx); err != nil {
+			return err
+		}
+
+		refreshToken, err = s.Create(ctx, tx, user.Id)
+		return err
+	})
+
+	if reused {
+		return nil, "", errRefreshReused
+	}
+
+	return user, refreshToken, err
+}
+
+// Revoke ends the session of the refresh token of the user, if any.
+func (s *SessionStore) Revoke(ctx context.Context, userID int64, token string) error {
+	_, err := s.db.NewUpdate().
+		Model((*models.RefreshToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("hash = ?", utils.HashRefreshToken(token)).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+
+	return err
+}
+
+// RevokeAccess rejects the access token until it expires. Entries of expired
+// tokens are purged on the way.
+func (s *SessionStore) RevokeAccess(ctx context.Context, claims *utils.AuthClaims) error {
+	return database.RunInTx(ctx, s.db, nil, func(tx bun.IDB) error {
+		if _, err := tx.NewDelete().
+			Model((*models.RevokedToken)(nil)).
+			Where("expires_at < ?", time.Now()).
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		_, err := tx.NewInsert().
+			Model(&models.RevokedToken{
+				Jti:       claims.ID,
+				ExpiresAt: claims.ExpiresAt.Time,
+			}).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx)
+
+		return err
+	})
+}
+
+// RevokeAll ends every session of the user and rejects the access tokens
+// issued so far.
+func (s *SessionStore) RevokeAll(ctx context.Context, userID int64) error {
+	return database.RunInTx(ctx, s.db, nil, func(tx bun.IDB) error {
+		return s.revokeAll(ctx, tx, userID)
+	})
+}
+
+func (s *SessionStore) revokeAll(ctx context.Context, tx bun.IDB, userID int64) error {
+	if _, err := tx.NewUpdate().
+		Model((*models.User)(nil)).
+		Set("session_generation = session_generation + 1").
+		Where("id = ?", userID).
+		Exec(ctx); err != nil {
+		return err
+	}
+
+	_, err := tx.NewUpdate().
+		Model((*models.RefreshToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+
+	return err
+}
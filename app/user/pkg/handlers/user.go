@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/core/database"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
 	"github.com/uptrace/bun"
 	"net/http"
 )
@@ -20,10 +24,10 @@ type UserService interface {
 type userService struct {
 	UserService
 
-	db *bun.DB
+	db bun.IDB
 }
 
-func NewUserService(db *bun.DB) UserService {
+func NewUserService(db bun.IDB) UserService {
 	return &userService{
 		db: db,
 	}
@@ -33,6 +37,10 @@ func (s *userService) GetOne(ctx context.Context, req *proto.GetUserRequest) (*p
 	user := new(models.User)
 
 	err := s.db.NewSelect().Model(&user).Relation("Roles").Where("id = ?", req.Id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, grpcutils.NotFound("user %d not found", req.Id)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -116,49 +124,58 @@ func (s *userService) Delete(ctx context.Context, req *proto.DeleteUserRequest)
 }
 
 func (s *userService) Assign(ctx context.Context, req *proto.AssignUserRequest) (*proto.AssignUserResponse, error) {
-	userRoles := new([]models.UserToRole)
-
-	if err := s.db.NewSelect().Model(userRoles).Where("user_id = ?", req.UserId).Scan(ctx); err != nil {
-		return nil, err
-	}
+	// A user without roles has no rows to lock, serializable keeps concurrent
+	// assignments from both inserting the same role.
+	err := database.RunInTx(ctx, s.db, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx bun.IDB) error {
+		userRoles := new([]models.UserToRole)
 
-	requestRoles := make(map[int64]int64, len(req.Roles))
-	currentRoles := make(map[int64]int64, len(*userRoles))
+		if err := tx.NewSelect().Model(userRoles).Where("user_id = ?", req.UserId).Apply(database.ForUpdate).Scan(ctx); err != nil {
+			return err
+		}
 
-	for idx, userRole := range *userRoles {
-		currentRoles[userRole.RoleID] = int64(idx)
-	}
+		requestRoles := make(map[int64]int64, len(req.Roles))
+		currentRoles := make(map[int64]int64, len(*userRoles))
 
-	for idx, reqRole := range req.Roles {
-		requestRoles[reqRole] = int64(idx)
-	}
+		for idx, userRole := range *userRoles {
+			currentRoles[userRole.RoleID] = int64(idx)
+		}
 
-	// Add roles that are in the request
-	for _, roleId := range req.Roles {
-		if _, ok := currentRoles[roleId]; !ok {
-			_, err := s.db.NewInsert().Model(&models.UserToRole{
-				UserID: req.UserId,
-				RoleID: roleId,
-			}).Exec(ctx)
+		for idx, reqRole := range req.Roles {
+			requestRoles[reqRole] = int64(idx)
+		}
 
-			if err != nil {
-				return nil, err
+		// Add roles that are in the request
+		for _, roleId := range req.Roles {
+			if _, ok := currentRoles[roleId]; !ok {
+				_, err := tx.NewInsert().Model(&models.UserToRole{
+					UserID: req.UserId,
+					RoleID: roleId,
+				}).Exec(ctx)
+
+				if err != nil {
+					return err
+				}
 			}
 		}
-	}
-
-	// Delete user's roles that are not in the request
-	for roleId := range currentRoles {
-		if _, ok := requestRoles[roleId]; !ok {
-			_, err := s.db.NewDelete().Model(&models.UserToRole{}).
-				Where("user_id = ?", req.UserId).
-				Where("role_id = ?", roleId).
-				Exec(ctx)
 
-			if err != nil {
-				return nil, err
+		// Delete user's roles that are not in the request
+		for roleId := range currentRoles {
+			if _, ok := requestRoles[roleId]; !ok {
+				_, err := tx.NewDelete().Model(&models.UserToRole{}).
+					Where("user_id = ?", req.UserId).
+					Where("role_id = ?", roleId).
+					Exec(ctx)
+
+				if err != nil {
+					return err
+				}
 			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &proto.AssignUserResponse{
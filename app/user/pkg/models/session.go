@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/uptrace/bun"
+	"time"
+)
+
+// RefreshToken is a session of a user. Only the hash of the token is stored,
+// it is revoked once exchanged for a new one.
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens,alias:rt"`
+
+	Id        int64     `json:"id" bun:",pk,autoincrement"`
+	UserId    int64     `json:"userId" bun:"user_id,notnull"`
+	Hash      string    `json:"-" bun:"hash,unique,notnull"`
+	ExpiresAt time.Time `bun:",notnull"`
+	RevokedAt time.Time `bun:",nullzero"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// RevokedToken is an access token rejected before it expires, by its jti.
+type RevokedToken struct {
+	bun.BaseModel `bun:"table:revoked_tokens,alias:rv"`
+
+	Jti       string    `bun:"jti,pk"`
+	ExpiresAt time.Time `bun:",notnull"`
+}
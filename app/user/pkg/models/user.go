@@ -15,6 +15,9 @@ type User struct {
 	Roles     []Role    `bun:"m2m:user_to_roles,join:User=Role"`
 	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
 	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+
+	// SessionGeneration is bumped to reject every access token issued before.
+	SessionGeneration int64 `bun:",notnull,default:0"`
 }
 
 type UserToRole struct {
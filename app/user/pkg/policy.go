@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/handlers"
+	proto2 "github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/server"
+	"google.golang.org/grpc"
+)
+
+// Policy lists the permission required by each RPC. Login, Register,
+// Validate, Refresh, Logout and GetKeys are left open.
+var Policy = authz.Policy{
+	method("getUser"):                  "user.read",
+	method("GetUsers"):                 "user.read",
+	method("CreateUser"):               "user.write",
+	method("UpdateUser"):               "user.write",
+	method("DeleteUser"):               "user.manage",
+	method("AssignUser"):               "user.manage",
+	method("GetServices"):              "user.read",
+	method("CreateServicePermissions"): "user.manage",
+	method("GetServicePermissions"):    "user.read",
+	method("GetRoles"):                 "user.read",
+	method("CreateRole"):               "user.manage",
+	method("RevokeSessions"):           "user.manage",
+}
+
+// SelfPolicy lets callers read their own permissions, which services use to
+// authorize them. Reading those of another user requires user.read, unless
+// the caller is one of the trusted services.
+var SelfPolicy = authz.SelfPolicy{
+	method("GetUserPermissions"): {
+		Owner: func(req any) int64 {
+			return req.(*proto2.GetUserPermissionsRequest).GetUserId()
+		},
+		Permission: "user.read",
+	},
+}
+
+// NewAuthorizer identifies the callers by their access token and loads their
+// permissions. auth and perms are getters as both are only created once the
+// database is connected, after the interceptors were installed.
+func NewAuthorizer(auth func() *utils.AuthWrapper, perms func() handlers.PermService, opts ...authz.Option) *authz.Authorizer {
+	return authz.NewAuthorizer(
+		func(ctx context.Context) (int64, error) {
+			claims, err := auth().ValidateToken(ctx, server.AuthToken(ctx))
+			if errors.Is(err, utils.ErrInvalidToken) {
+				return 0, grpcutils.Unauthenticated("%v", err)
+			}
+			if err != nil {
+				return 0, err
+			}
+			return claims.UserId, nil
+		},
+		func(ctx context.Context, userID int64) (authz.Matrix, error) {
+			res, err := perms().GetUserPermissions(ctx, &proto2.GetUserPermissionsRequest{UserId: userID})
+			if err != nil {
+				return nil, err
+			}
+			return res.GetMatrix(), nil
+		},
+		opts...,
+	)
+}
+
+// Interceptors enforces Policy and SelfPolicy with the authorizer.
+func Interceptors(authorizer *authz.Authorizer) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	unary, stream := authorizer.Interceptors(Policy)
+	unary = append(unary, authorizer.SelfInterceptor(SelfPolicy))
+
+	return unary, stream
+}
+
+func method(name string) string {
+	return authz.Method(proto2.UserService_ServiceDesc, name)
+}
@@ -17,9 +17,9 @@ type Server struct {
 	userService handlers.UserService
 }
 
-func NewServer(db *bun.DB, w *utils.AuthWrapper) *Server {
+func NewServer(db bun.IDB, w *utils.AuthWrapper, sessions *handlers.SessionStore) *Server {
 	return &Server{
-		authService: handlers.NewAuthService(w, db),
+		authService: handlers.NewAuthService(w, sessions, db),
 		permService: handlers.NewPermService(db),
 		roleService: handlers.NewRoleService(db),
 		userService: handlers.NewUserService(db),
@@ -44,7 +44,7 @@ func (s *Server) AssignUser(ctx context.Context, req *proto2.AssignUserRequest)
 func (s *Server) GetServices(ctx context.Context, req *proto2.GetServicesRequest) (*proto2.GetServicesResponse, error) {
 	return s.permService.GetServices(ctx)
 }
-func (s *Server) CreateServicePermission(ctx context.Context, req *proto2.CreateServicePermissionsRequest) (*proto2.CreateServicePermissionsResponse, error) {
+func (s *Server) CreateServicePermissions(ctx context.Context, req *proto2.CreateServicePermissionsRequest) (*proto2.CreateServicePermissionsResponse, error) {
 	return s.permService.CreateServicePermissions(ctx, req)
 }
 func (s *Server) GetServicePermissions(ctx context.Context, req *proto2.GetServicePermissionsRequest) (*proto2.GetServicePermissionsResponse, error) {
@@ -62,6 +62,18 @@ func (s *Server) Register(ctx context.Context, req *proto2.RegisterRequest) (*pr
 func (s *Server) Validate(ctx context.Context, req *proto2.ValidateRequest) (*proto2.ValidateResponse, error) {
 	return s.authService.Validate(ctx, req)
 }
+func (s *Server) Refresh(ctx context.Context, req *proto2.RefreshRequest) (*proto2.RefreshResponse, error) {
+	return s.authService.Refresh(ctx, req)
+}
+func (s *Server) Logout(ctx context.Context, req *proto2.LogoutRequest) (*proto2.LogoutResponse, error) {
+	return s.authService.Logout(ctx, req)
+}
+func (s *Server) RevokeSessions(ctx context.Context, req *proto2.RevokeSessionsRequest) (*proto2.RevokeSessionsResponse, error) {
+	return s.authService.RevokeSessions(ctx, req)
+}
+func (s *Server) GetKeys(ctx context.Context, req *proto2.GetKeysRequest) (*proto2.GetKeysResponse, error) {
+	return s.authService.GetKeys(ctx)
+}
 func (s *Server) GetRoles(ctx context.Context, req *proto2.GetRolesRequest) (*proto2.GetRolesResponse, error) {
 	return s.roleService.GetAll(ctx)
 }
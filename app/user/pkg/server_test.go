@@ -0,0 +1,325 @@
+package pkg_test
+
+import (
+	"context"
+	"github.com/alpha-omega-corp/cloud/app/user/cmd/migrations"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/handlers"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/server"
+	harness "github.com/alpha-omega-corp/cloud/core/testing"
+	"github.com/uptrace/bun"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// Users of the fixtures, all with the password "test".
+const (
+	adminEmail = "bleyo@alphomega.org"
+	guestEmail = "guest@alphomega.org"
+	password   = "test"
+)
+
+func newClient(t *testing.T) proto.UserServiceClient {
+	t.Helper()
+
+	var (
+		auth  *utils.AuthWrapper
+		perms handlers.PermService
+	)
+	authorizer := pkg.NewAuthorizer(
+		func() *utils.AuthWrapper { return auth },
+		func() handlers.PermService { return perms },
+	)
+	unary, stream := pkg.Interceptors(authorizer)
+
+	h := harness.New(t, func(db *bun.DB, grpc *grpc.Server) {
+		sessions := handlers.NewSessionStore(db, 0)
+		auth = utils.NewAuthWrapper("secret", utils.WithRevocations(sessions))
+		perms = handlers.NewPermService(db)
+		proto.RegisterUserServiceServer(grpc, pkg.NewServer(db, auth, sessions))
+	},
+		harness.WithMigrations(migrations.FS),
+		harness.WithModels(
+			(*models.UserToRole)(nil),
+			(*models.User)(nil),
+			(*models.Role)(nil),
+			(*models.Service)(nil),
+			(*models.Permission)(nil),
+		),
+		harness.WithFixtures(os.DirFS("../cmd/fixtures"), "fixture.yml"),
+		harness.WithServerOptions(
+			server.WithUnaryInterceptors(unary...),
+			server.WithStreamInterceptors(stream...),
+		),
+	)
+
+	return proto.NewUserServiceClient(h.Conn)
+}
+
+func login(t *testing.T, client proto.UserServiceClient, email string) *proto.LoginResponse {
+	t.Helper()
+
+	res, err := client.Login(context.Background(), &proto.LoginRequest{Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("login %s: %v", email, err)
+	}
+
+	return res
+}
+
+func as(session *proto.LoginResponse) context.Context {
+	return server.WithAuthToken(context.Background(), session.GetToken())
+}
+
+func assertCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != code {
+		t.Fatalf("got code %s, want %s (%v)", got, code, err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	req := &proto.RegisterRequest{Username: "jane", Email: "jane@example.org", Password: "secret"}
+
+	if _, err := client.Register(ctx, req); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	_, err := client.Register(ctx, req)
+	assertCode(t, err, codes.AlreadyExists)
+
+	res, err := client.Login(ctx, &proto.LoginRequest{Email: req.Email, Password: req.Password})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if res.GetUser().GetEmail() != req.Email {
+		t.Fatalf("logged in as %q, want %q", res.GetUser().GetEmail(), req.Email)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	client := newClient(t)
+
+	tests := []struct {
+		name string
+		req  *proto.RegisterRequest
+		want map[string]string
+	}{
+		{
+			name: "empty",
+			req:  &proto.RegisterRequest{},
+			want: map[string]string{
+				"email":    "is required",
+				"username": "is required",
+				"password": "is required",
+			},
+		},
+		{
+			name: "invalid email",
+			req:  &proto.RegisterRequest{Username: "jane", Email: "jane", Password: "secret"},
+			want: map[string]string{"email": "is not a valid email address"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Register(context.Background(), tt.req)
+			assertCode(t, err, codes.InvalidArgument)
+
+			if got := grpcutils.Violations(err); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	_, err := client.Login(ctx, &proto.LoginRequest{Email: adminEmail, Password: "wrong"})
+	assertCode(t, err, codes.Unauthenticated)
+
+	_, err = client.Login(ctx, &proto.LoginRequest{Email: "nobody@example.org", Password: password})
+	assertCode(t, err, codes.Unauthenticated)
+
+	res := login(t, client, adminEmail)
+	if res.GetToken() == "" || res.GetRefreshToken() == "" {
+		t.Fatalf("login returned no tokens: %v", res)
+	}
+
+	validated, err := client.Validate(ctx, &proto.ValidateRequest{Token: res.GetToken()})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if validated.GetUser().GetId() != res.GetUser().GetId() {
+		t.Fatalf("validated user %d, want %d", validated.GetUser().GetId(), res.GetUser().GetId())
+	}
+
+	_, err = client.Validate(ctx, &proto.ValidateRequest{Token: res.GetToken() + "x"})
+	assertCode(t, err, codes.Unauthenticated)
+}
+
+func TestAssignUser(t *testing.T) {
+	client := newClient(t)
+	ctx := as(login(t, client, adminEmail))
+	guest := login(t, client, guestEmail).GetUser().GetId()
+
+	_, err := client.AssignUser(ctx, &proto.AssignUserRequest{UserId: guest, Roles: []int64{999}})
+	assertCode(t, err, codes.FailedPrecondition)
+
+	roles, err := client.GetRoles(ctx, &proto.GetRolesRequest{})
+	if err != nil {
+		t.Fatalf("get roles: %v", err)
+	}
+
+	var admin int64
+	for _, role := range roles.GetRoles() {
+		if role.GetName() == "admin" {
+			admin = role.GetId()
+		}
+	}
+
+	if _, err := client.AssignUser(ctx, &proto.AssignUserRequest{UserId: guest, Roles: []int64{admin}}); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+
+	perms, err := client.GetUserPermissions(ctx, &proto.GetUserPermissionsRequest{UserId: guest})
+	if err != nil {
+		t.Fatalf("get permissions: %v", err)
+	}
+	if !perms.GetMatrix()["user.manage"] || perms.GetMatrix()["home.read"] {
+		t.Fatalf("unexpected matrix after assigning admin only: %v", perms.GetMatrix())
+	}
+}
+
+func TestCreateServicePermissions(t *testing.T) {
+	client := newClient(t)
+	ctx := as(login(t, client, adminEmail))
+	guest := login(t, client, guestEmail)
+
+	roles, err := client.GetRoles(ctx, &proto.GetRolesRequest{})
+	if err != nil {
+		t.Fatalf("get roles: %v", err)
+	}
+	services, err := client.GetServices(ctx, &proto.GetServicesRequest{})
+	if err != nil {
+		t.Fatalf("get services: %v", err)
+	}
+
+	var role, service int64
+	for _, r := range roles.GetRoles() {
+		if r.GetName() == "guest" {
+			role = r.GetId()
+		}
+	}
+	for _, s := range services.GetServices() {
+		if s.GetName() == "docker" {
+			service = s.GetId()
+		}
+	}
+
+	req := &proto.CreateServicePermissionsRequest{RoleId: role, ServiceId: service, CanRead: true}
+
+	_, err = client.CreateServicePermissions(as(guest), req)
+	assertCode(t, err, codes.PermissionDenied)
+
+	_, err = client.CreateServicePermissions(ctx, &proto.CreateServicePermissionsRequest{RoleId: 999, ServiceId: service, CanRead: true})
+	assertCode(t, err, codes.FailedPrecondition)
+
+	if _, err := client.CreateServicePermissions(ctx, req); err != nil {
+		t.Fatalf("create permissions: %v", err)
+	}
+
+	perms, err := client.GetUserPermissions(ctx, &proto.GetUserPermissionsRequest{UserId: guest.GetUser().GetId()})
+	if err != nil {
+		t.Fatalf("get permissions: %v", err)
+	}
+	if !perms.GetMatrix()["docker.read"] || perms.GetMatrix()["docker.write"] {
+		t.Fatalf("unexpected matrix after granting docker.read: %v", perms.GetMatrix())
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	session := login(t, client, adminEmail)
+
+	refreshed, err := client.Refresh(ctx, &proto.RefreshRequest{RefreshToken: session.GetRefreshToken()})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if refreshed.GetRefreshToken() == session.GetRefreshToken() {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Reusing the rotated token revokes every session of the user.
+	_, err = client.Refresh(ctx, &proto.RefreshRequest{RefreshToken: session.GetRefreshToken()})
+	assertCode(t, err, codes.Unauthenticated)
+
+	_, err = client.Refresh(ctx, &proto.RefreshRequest{RefreshToken: refreshed.GetRefreshToken()})
+	assertCode(t, err, codes.Unauthenticated)
+
+	_, err = client.Validate(ctx, &proto.ValidateRequest{Token: refreshed.GetToken()})
+	assertCode(t, err, codes.Unauthenticated)
+
+	// Sessions started after the revocation carry the new generation.
+	session = login(t, client, adminEmail)
+	if _, err := client.Validate(ctx, &proto.ValidateRequest{Token: session.GetToken()}); err != nil {
+		t.Fatalf("validate a session started after the revocation: %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	session := login(t, client, adminEmail)
+	other := login(t, client, adminEmail)
+
+	_, err := client.Logout(ctx, &proto.LogoutRequest{Token: session.GetToken(), RefreshToken: session.GetRefreshToken()})
+	if err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+
+	_, err = client.Validate(ctx, &proto.ValidateRequest{Token: session.GetToken()})
+	assertCode(t, err, codes.Unauthenticated)
+
+	if _, err := client.Validate(ctx, &proto.ValidateRequest{Token: other.GetToken()}); err != nil {
+		t.Fatalf("other session was revoked: %v", err)
+	}
+
+	_, err = client.Refresh(ctx, &proto.RefreshRequest{RefreshToken: session.GetRefreshToken()})
+	assertCode(t, err, codes.Unauthenticated)
+}
+
+func TestGetUserPermissions(t *testing.T) {
+	client := newClient(t)
+	admin := login(t, client, adminEmail)
+	guest := login(t, client, guestEmail)
+	req := &proto.GetUserPermissionsRequest{UserId: admin.GetUser().GetId()}
+
+	_, err := client.GetUserPermissions(context.Background(), req)
+	assertCode(t, err, codes.Unauthenticated)
+
+	_, err = client.GetUserPermissions(as(guest), req)
+	assertCode(t, err, codes.PermissionDenied)
+
+	if _, err := client.GetUserPermissions(as(guest), &proto.GetUserPermissionsRequest{UserId: guest.GetUser().GetId()}); err != nil {
+		t.Fatalf("own permissions: %v", err)
+	}
+
+	if _, err := client.GetUserPermissions(as(admin), &proto.GetUserPermissionsRequest{UserId: guest.GetUser().GetId()}); err != nil {
+		t.Fatalf("permissions of another user with user.read: %v", err)
+	}
+}
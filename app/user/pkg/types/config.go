@@ -0,0 +1,52 @@
+package types
+
+import (
+	"errors"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/utils"
+	st "github.com/alpha-omega-corp/cloud/core/types"
+	"time"
+)
+
+// Config of the user service. AccessTTL is the lifetime of the access
+// tokens, RefreshTTL the one of the sessions they are refreshed from.
+//
+// Secret is required and usually a reference such as env://USER_JWT_SECRET,
+// so that it never sits in the embedded configuration nor in etcd.
+//
+// Tokens are signed with the Ed25519 key of Keys named by SigningKey, every
+// key of Keys is published to verify them. Keys are PEM encoded and indexed
+// by a lowercase key id. Without keys, tokens are signed with Secret. Once
+// keys are set, HMAC tokens are rejected, unless HMACMigration keeps
+// verifying them with Secret until the ones issued before expire. Anyone
+// knowing Secret can forge tokens meanwhile.
+//
+// Tokens name Issuer and Audience, which the services verifying them expect,
+// and their time based claims tolerate a ClockSkew between the services.
+type Config struct {
+	Secret        st.Secret            `mapstructure:"secret" validate:"required"`
+	Keys          map[string]st.Secret `mapstructure:"keys"`
+	SigningKey    string               `mapstructure:"signing_key"`
+	HMACMigration bool                 `mapstructure:"hmac_migration"`
+	AccessTTL     time.Duration        `mapstructure:"access_ttl" default:"15m"`
+	RefreshTTL    time.Duration        `mapstructure:"refresh_ttl" default:"720h"`
+	Issuer        string               `mapstructure:"issuer" default:"auth-svc"`
+	Audience      string               `mapstructure:"audience" default:"cloud"`
+	ClockSkew     time.Duration        `mapstructure:"clock_skew" default:"30s"`
+}
+
+// KeySet returns the keys of the configuration, nil when none is set.
+func (c *Config) KeySet() (*utils.KeySet, error) {
+	if len(c.Keys) == 0 {
+		if c.Secret.Value() == "" {
+			return nil, errors.New("either keys or secret must be configured")
+		}
+		return nil, nil
+	}
+
+	pems := make(map[string]string, len(c.Keys))
+	for kid, key := range c.Keys {
+		pems[kid] = key.Value()
+	}
+
+	return utils.NewKeySet(c.SigningKey, pems)
+}
@@ -1,47 +1,287 @@
 package utils
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
-	"github.com/golang-jwt/jwt"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/golang-jwt/jwt/v5"
+	"sort"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	DefaultAccessTTL = 15 * time.Minute
+	DefaultIssuer    = "auth-svc"
+	DefaultAudience  = "cloud"
+
+	// DefaultLeeway tolerates the clock skew between the issuer and the
+	// services verifying the tokens.
+	DefaultLeeway = 30 * time.Second
+
+	// DefaultMaxClaimsSize bounds the encoded claims, so that tokens fit in
+	// the request headers whatever the number of roles of the user.
+	DefaultMaxClaimsSize = 4 << 10
+)
+
+// Revocations reports whether a token was revoked before its expiry.
+type Revocations interface {
+	Revoked(ctx context.Context, claims *AuthClaims) (bool, error)
+}
+
+// AuthWrapper issues and verifies the access tokens. Tokens are signed with
+// the signing key of the key set when one is set, with the HMAC secret
+// otherwise. Services holding only the public keys verify with a KeySource.
+// Once keys are set, HMAC tokens are rejected unless SetHMACMigration allows
+// them.
 type AuthWrapper struct {
-	secretKey string
-	expiresAt int64
+	signing   atomic.Pointer[Signing]
+	expiresIn time.Duration
+
+	issuer      string
+	audience    string
+	leeway      time.Duration
+	maxSize     int
+	source      KeySource
+	revocations Revocations
+}
+
+// Signing holds the keys of a wrapper. It is replaced as a whole, so that a
+// token is never checked against keys and settings of different versions.
+type Signing struct {
+	Secret        string
+	Keys          *KeySet
+	HMACMigration bool
+}
+
+type Option func(w *AuthWrapper)
+
+// WithExpiry sets the lifetime of the access tokens.
+func WithExpiry(ttl time.Duration) Option {
+	return func(w *AuthWrapper) {
+		w.expiresIn = ttl
+	}
+}
+
+// WithIssuer sets the issuer of the tokens, tokens from another issuer are
+// rejected.
+func WithIssuer(issuer string) Option {
+	return func(w *AuthWrapper) {
+		w.issuer = issuer
+	}
+}
+
+// WithAudience sets the audience of the tokens, tokens not intended for it
+// are rejected.
+func WithAudience(audience string) Option {
+	return func(w *AuthWrapper) {
+		w.audience = audience
+	}
+}
+
+// WithLeeway sets the clock skew tolerated on the time based claims.
+func WithLeeway(leeway time.Duration) Option {
+	return func(w *AuthWrapper) {
+		w.leeway = leeway
+	}
+}
+
+// WithMaxClaimsSize sets the size of the encoded claims above which the
+// permissions, then the roles, are left out of the tokens.
+func WithMaxClaimsSize(size int) Option {
+	return func(w *AuthWrapper) {
+		w.maxSize = size
+	}
+}
+
+// WithKeySource verifies the EdDSA tokens with the public keys of source
+// instead of the key set of the wrapper.
+func WithKeySource(source KeySource) Option {
+	return func(w *AuthWrapper) {
+		w.source = source
+	}
+}
+
+// WithRevocations rejects the tokens that revocations reports as revoked.
+func WithRevocations(revocations Revocations) Option {
+	return func(w *AuthWrapper) {
+		w.revocations = revocations
+	}
+}
+
+func NewAuthWrapper(key string, opts ...Option) *AuthWrapper {
+	w := &AuthWrapper{
+		expiresIn: DefaultAccessTTL,
+
+		issuer:   DefaultIssuer,
+		audience: DefaultAudience,
+		leeway:   DefaultLeeway,
+		maxSize:  DefaultMaxClaimsSize,
+	}
+	w.signing.Store(&Signing{Secret: key})
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Update replaces the secret, the key set and the HMAC migration flag at
+// once. Tokens signed with a key missing from the new ones are rejected
+// afterwards.
+func (w *AuthWrapper) Update(signing Signing) {
+	w.signing.Store(&signing)
+}
+
+// SetSecret replaces the signing key, tokens signed with the previous key are
+// rejected afterwards.
+func (w *AuthWrapper) SetSecret(key string) {
+	w.update(func(s *Signing) { s.Secret = key })
+}
+
+// SetKeys replaces the key set signing the tokens. Tokens signed with a key
+// missing from the new set are rejected afterwards.
+func (w *AuthWrapper) SetKeys(keys *KeySet) {
+	w.update(func(s *Signing) { s.Keys = keys })
+}
+
+// SetHMACMigration keeps accepting the HMAC tokens signed with the secret
+// while keys are set, for the time the tokens issued before the move to keys
+// expire. Anyone knowing the secret can forge tokens meanwhile.
+func (w *AuthWrapper) SetHMACMigration(enabled bool) {
+	w.update(func(s *Signing) { s.HMACMigration = enabled })
+}
+
+// update replaces the signing settings with a modified copy, retrying when
+// another update won the race.
+func (w *AuthWrapper) update(modify func(s *Signing)) {
+	for {
+		current := w.signing.Load()
+		next := *current
+		modify(&next)
+
+		if w.signing.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
 
-	provider string
+// Keys returns the key set of the wrapper, nil when tokens are signed with
+// the HMAC secret.
+func (w *AuthWrapper) Keys() *KeySet {
+	return w.signing.Load().Keys
 }
 
-func NewAuthWrapper(key string) *AuthWrapper {
-	return &AuthWrapper{
-		secretKey: key,
-		expiresAt: 24,
+func (w *AuthWrapper) keySource(signing *Signing) KeySource {
+	if w.source != nil {
+		return w.source
+	}
 
-		provider: "auth-svc",
+	if signing.Keys != nil {
+		return signing.Keys
 	}
+
+	return nil
 }
 
+var (
+	// ErrInvalidToken is wrapped by the errors returned for tokens that are
+	// malformed, expired or revoked.
+	ErrInvalidToken = errors.New("invalid token")
+	ErrRevoked      = fmt.Errorf("%w: token is revoked", ErrInvalidToken)
+
+	// ErrHMACDisabled rejects the HMAC tokens once keys are configured.
+	ErrHMACDisabled = fmt.Errorf("%w: hmac tokens are disabled by the keys", ErrUnknownKey)
+)
+
+// AuthClaims are the claims of the access tokens. Permissions lists the
+// granted entries of the permission matrix of the user, it is null when left
+// out of the token and the matrix must be looked up instead.
 type AuthClaims struct {
-	jwt.StandardClaims
-	Id    int64
-	Email string
+	jwt.RegisteredClaims
+	UserId      int64    `json:"uid"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms"`
+	Generation  int64    `json:"gen,omitempty"`
 }
 
-func (w *AuthWrapper) GenerateToken(user models.User) (signedToken string, err error) {
+// Matrix returns the permission matrix carried by the token, if any.
+func (c *AuthClaims) Matrix() (authz.Matrix, bool) {
+	if c.Permissions == nil {
+		return nil, false
+	}
+
+	matrix := make(authz.Matrix, len(c.Permissions))
+	for _, permission := range c.Permissions {
+		matrix[permission] = true
+	}
+
+	return matrix, true
+}
+
+// GenerateToken issues an access token for the user, carrying the names of
+// its loaded roles and the permissions granted by matrix.
+func (w *AuthWrapper) GenerateToken(user models.User, matrix authz.Matrix) (signedToken string, err error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &AuthClaims{
-		Id:    user.Id,
-		Email: user.Email,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(w.expiresAt)).Unix(),
-			Issuer:    w.provider,
+		UserId:      user.Id,
+		Email:       user.Email,
+		Permissions: make([]string, 0, len(matrix)),
+		Generation:  user.SessionGeneration,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatInt(user.Id, 10),
+			Issuer:    w.issuer,
+			Audience:  jwt.ClaimStrings{w.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(w.expiresIn)),
 		},
 	}
 
+	for _, role := range user.Roles {
+		claims.Roles = append(claims.Roles, role.Name)
+	}
+
+	for permission, granted := range matrix {
+		if granted {
+			claims.Permissions = append(claims.Permissions, permission)
+		}
+	}
+	sort.Strings(claims.Permissions)
+
+	if err := w.capClaims(claims); err != nil {
+		return "", err
+	}
+
+	signing := w.signing.Load()
+
+	var key interface{} = []byte(signing.Secret)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	signedToken, err = token.SignedString([]byte(w.secretKey))
+	if keys := signing.Keys; keys != nil {
+		kid, private := keys.SigningKey()
+		token = jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = kid
+		key = private
+	}
+
+	signedToken, err = token.SignedString(key)
 
 	if err != nil {
 		return "", err
@@ -50,29 +290,119 @@ func (w *AuthWrapper) GenerateToken(user models.User) (signedToken string, err e
 	return signedToken, nil
 }
 
-func (w *AuthWrapper) ValidateToken(signedToken string) (claims *AuthClaims, err error) {
+// capClaims leaves the permissions, then the roles, out of the claims while
+// they exceed the maximum size.
+func (w *AuthWrapper) capClaims(claims *AuthClaims) error {
+	for _, drop := range []func(){
+		func() { claims.Permissions = nil },
+		func() { claims.Roles = nil },
+	} {
+		encoded, err := json.Marshal(claims)
+		if err != nil {
+			return err
+		}
+
+		if len(encoded) <= w.maxSize {
+			return nil
+		}
+		drop()
+	}
+
+	return nil
+}
+
+// ValidateToken checks the signature, issuer, audience and validity period of
+// the token, then that it was not revoked. Rejected tokens are reported with
+// ErrInvalidToken.
+func (w *AuthWrapper) ValidateToken(ctx context.Context, signedToken string) (claims *AuthClaims, err error) {
+	signing := w.signing.Load()
+
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&AuthClaims{},
 		func(token *jwt.Token) (interface{}, error) {
-			return []byte(w.secretKey), nil
+			return w.verificationKey(ctx, signing, token)
 		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(w.issuer),
+		jwt.WithAudience(w.audience),
+		jwt.WithLeeway(w.leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
 	)
 
 	if err != nil {
-		return
+		// The keys failed to load, which is reported as is.
+		if errors.Is(err, jwt.ErrTokenUnverifiable) && !errors.Is(err, ErrUnknownKey) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*AuthClaims)
 
-	if !ok {
-		return nil, errors.New("unable to parse claims")
+	if !ok || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: unable to parse claims", ErrInvalidToken)
 	}
 
-	if claims.ExpiresAt < time.Now().Local().Unix() {
-		return nil, errors.New("token is expired")
+	if w.revocations != nil {
+		revoked, err := w.revocations.Revoked(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		if revoked {
+			return nil, ErrRevoked
+		}
 	}
 
 	return claims, nil
+}
+
+// verificationKey selects the key verifying token by its algorithm, so that
+// a token cannot pick a weaker verification than the issuer uses.
+func (w *AuthWrapper) verificationKey(ctx context.Context, signing *Signing, token *jwt.Token) (interface{}, error) {
+	source := w.keySource(signing)
+
+	if token.Method == jwt.SigningMethodHS256 {
+		if source != nil && !signing.HMACMigration {
+			return nil, ErrHMACDisabled
+		}
+		if signing.Secret != "" {
+			return []byte(signing.Secret), nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	if source == nil {
+		return nil, ErrUnknownKey
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return source.PublicKey(ctx, kid)
+}
+
+// NewRefreshToken returns a random refresh token and the hash to store.
+func NewRefreshToken() (token string, hash string, err error) {
+	token, err = randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
 
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
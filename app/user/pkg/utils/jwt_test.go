@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/models"
+	"github.com/alpha-omega-corp/cloud/core/authz"
+	"github.com/golang-jwt/jwt/v5"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testUser = models.User{
+	Id:    7,
+	Email: "jane@example.org",
+	Roles: []models.Role{{Name: "admin"}},
+
+	SessionGeneration: 3,
+}
+
+func newKeySet(t *testing.T, signing string, kids ...string) *KeySet {
+	t.Helper()
+
+	pems := make(map[string]string, len(kids))
+	for _, kid := range kids {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("generate key: %v", err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			t.Fatalf("marshal key: %v", err)
+		}
+		pems[kid] = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	}
+
+	keys, err := NewKeySet(signing, pems)
+	if err != nil {
+		t.Fatalf("key set: %v", err)
+	}
+
+	return keys
+}
+
+// sign issues a token with arbitrary claims, as a forger would.
+func sign(t *testing.T, claims *AuthClaims, secret string) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	return token
+}
+
+func validClaims(now time.Time) *AuthClaims {
+	return &AuthClaims{
+		UserId: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    DefaultIssuer,
+			Audience:  jwt.ClaimStrings{DefaultAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+func TestTokenClaims(t *testing.T) {
+	w := NewAuthWrapper("secret")
+
+	token, err := w.GenerateToken(testUser, authz.Matrix{"user.read": true, "user.write": false})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	claims, err := w.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if claims.UserId != testUser.Id || claims.Subject != "7" || claims.Email != testUser.Email || claims.Generation != 3 {
+		t.Fatalf("unexpected identity %+v", claims)
+	}
+	if !reflect.DeepEqual(claims.Roles, []string{"admin"}) {
+		t.Fatalf("got roles %v, want [admin]", claims.Roles)
+	}
+
+	matrix, ok := claims.Matrix()
+	if !ok || !reflect.DeepEqual(matrix, authz.Matrix{"user.read": true}) {
+		t.Fatalf("got matrix %v (%t), want the granted permissions", matrix, ok)
+	}
+}
+
+func TestTokenClaimsCap(t *testing.T) {
+	matrix := make(authz.Matrix)
+	for i := 0; i < 200; i++ {
+		matrix[fmt.Sprintf("service%03d.read", i)] = true
+	}
+
+	w := NewAuthWrapper("secret", WithMaxClaimsSize(1024))
+	token, err := w.GenerateToken(testUser, matrix)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	claims, err := w.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if _, ok := claims.Matrix(); ok {
+		t.Fatal("oversized permissions were kept")
+	}
+	if len(claims.Roles) != 1 {
+		t.Fatalf("got roles %v, want them kept", claims.Roles)
+	}
+}
+
+func TestValidateTokenRejects(t *testing.T) {
+	now := time.Now()
+	w := NewAuthWrapper("secret", WithLeeway(30*time.Second))
+
+	for name, claims := range map[string]func(c *AuthClaims){
+		"issuer":      func(c *AuthClaims) { c.Issuer = "someone-else" },
+		"audience":    func(c *AuthClaims) { c.Audience = jwt.ClaimStrings{"other"} },
+		"expired":     func(c *AuthClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) },
+		"no expiry":   func(c *AuthClaims) { c.ExpiresAt = nil },
+		"not before":  func(c *AuthClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) },
+		"issued late": func(c *AuthClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) },
+		"no iat":      func(c *AuthClaims) { c.IssuedAt = nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := validClaims(now)
+			claims(c)
+
+			_, err := w.ValidateToken(context.Background(), sign(t, c, "secret"))
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenLeeway(t *testing.T) {
+	now := time.Now()
+	w := NewAuthWrapper("secret", WithLeeway(30*time.Second))
+
+	c := validClaims(now.Add(10 * time.Second))
+	if _, err := w.ValidateToken(context.Background(), sign(t, c, "secret")); err != nil {
+		t.Fatalf("token from a clock 10s ahead: %v", err)
+	}
+
+	c = validClaims(now)
+	c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second))
+	if _, err := w.ValidateToken(context.Background(), sign(t, c, "secret")); err != nil {
+		t.Fatalf("token expired 10s ago: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	w := NewAuthWrapper("")
+	w.SetKeys(newKeySet(t, "k1", "k1"))
+
+	old, err := w.GenerateToken(testUser, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	// Retiring k1 rejects the tokens it signed.
+	rotated := newKeySet(t, "k2", "k2")
+	w.SetKeys(rotated)
+
+	if _, err := w.ValidateToken(ctx, old); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of a retired key: got %v, want ErrInvalidToken", err)
+	}
+
+	token, err := w.GenerateToken(testUser, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	// A verifier holding only the public keys accepts the new tokens.
+	verifier := NewAuthWrapper("", WithKeySource(rotated))
+	if _, err := verifier.ValidateToken(ctx, token); err != nil {
+		t.Fatalf("verify with the public keys: %v", err)
+	}
+}
+
+func TestHMACRejectedWithKeys(t *testing.T) {
+	ctx := context.Background()
+	w := NewAuthWrapper("secret")
+	w.SetKeys(newKeySet(t, "k1", "k1"))
+
+	forged := sign(t, validClaims(time.Now()), "secret")
+	if _, err := w.ValidateToken(ctx, forged); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("hmac token with keys configured: got %v, want ErrInvalidToken", err)
+	}
+
+	verifier := NewAuthWrapper("secret", WithKeySource(w.Keys()))
+	if _, err := verifier.ValidateToken(ctx, forged); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("hmac token with a key source: got %v, want ErrInvalidToken", err)
+	}
+
+	// The migration keeps verifying them until turned off.
+	w.SetHMACMigration(true)
+	if _, err := w.ValidateToken(ctx, forged); err != nil {
+		t.Fatalf("hmac token during the migration: %v", err)
+	}
+}
+
+func TestUpdateReplacesSigningAtOnce(t *testing.T) {
+	ctx := context.Background()
+	w := NewAuthWrapper("secret")
+	hmac := sign(t, validClaims(time.Now()), "secret")
+
+	// Moving to keys with the migration on never rejects the HMAC tokens,
+	// even to a validation running concurrently.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			if _, err := w.ValidateToken(ctx, hmac); err != nil {
+				t.Errorf("hmac token during the update: %v", err)
+				return
+			}
+		}
+	}()
+
+	w.Update(Signing{Secret: "secret", Keys: newKeySet(t, "k1", "k1"), HMACMigration: true})
+	<-done
+
+	if w.Keys() == nil {
+		t.Fatal("keys were not set")
+	}
+	if _, err := w.ValidateToken(ctx, hmac); err != nil {
+		t.Fatalf("hmac token after the update: %v", err)
+	}
+}
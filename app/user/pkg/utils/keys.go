@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultKeysTTL = 5 * time.Minute
+
+// minRefresh limits how often an unknown key id triggers a new fetch.
+const minRefresh = 10 * time.Second
+
+// After a failed fetch, the next one waits for a backoff doubling from
+// minRetry up to maxRetry. fetchTimeout bounds a fetch shared by callers.
+const (
+	minRetry     = time.Second
+	maxRetry     = time.Minute
+	fetchTimeout = 5 * time.Second
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySource returns the public key verifying the tokens signed with kid.
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (ed25519.PublicKey, error)
+}
+
+// KeySet holds the Ed25519 keys of the issuer. Every key verifies tokens,
+// only the signing one issues them, so that a new key can be published
+// before it is used and an old one kept until its tokens expire.
+type KeySet struct {
+	signing string
+	keys    map[string]ed25519.PrivateKey
+}
+
+var _ KeySource = (*KeySet)(nil)
+
+// NewKeySet parses the PEM encoded PKCS #8 private keys, as generated by
+// `openssl genpkey -algorithm ed25519`, indexed by key id.
+func NewKeySet(signing string, pems map[string]string) (*KeySet, error) {
+	ks := &KeySet{
+		signing: signing,
+		keys:    make(map[string]ed25519.PrivateKey, len(pems)),
+	}
+
+	for kid, pem := range pems {
+		key, err := jwt.ParseEdPrivateKeyFromPEM([]byte(pem))
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("key %s: not an ed25519 key", kid)
+		}
+		ks.keys[kid] = private
+	}
+
+	if _, ok := ks.keys[signing]; !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", signing)
+	}
+
+	return ks, nil
+}
+
+func (ks *KeySet) SigningKey() (string, ed25519.PrivateKey) {
+	return ks.signing, ks.keys[ks.signing]
+}
+
+func (ks *KeySet) PublicKey(_ context.Context, kid string) (ed25519.PublicKey, error) {
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+func (ks *KeySet) JWKS() *proto.GetKeysResponse {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	res := &proto.GetKeysResponse{}
+	for _, kid := range kids {
+		res.Keys = append(res.Keys, &proto.Key{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Kid: kid,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Use: "sig",
+			X:   base64.RawURLEncoding.EncodeToString(ks.keys[kid].Public().(ed25519.PublicKey)),
+		})
+	}
+
+	return res
+}
+
+// RemoteKeys fetches the key set published by the user service, for the
+// services verifying tokens without holding the private keys. The set is
+// refreshed once stale, or early when a token names an unknown key.
+// Concurrent refreshes share a single fetch, and failed ones back off.
+type RemoteKeys struct {
+	client proto.UserServiceClient
+	ttl    time.Duration
+	group  singleflight.Group
+
+	mu       sync.Mutex
+	keys     map[string]ed25519.PublicKey
+	fetched  time.Time
+	failures int
+	retryAt  time.Time
+}
+
+var _ KeySource = (*RemoteKeys)(nil)
+
+func NewRemoteKeys(client proto.UserServiceClient, ttl time.Duration) *RemoteKeys {
+	if ttl <= 0 {
+		ttl = DefaultKeysTTL
+	}
+
+	return &RemoteKeys{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (r *RemoteKeys) PublicKey(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	key, ok, fresh := r.lookup(kid)
+	if fresh {
+		return key, keyError(ok)
+	}
+
+	_, err, _ := r.group.Do("keys", func() (any, error) {
+		// The fetch is shared, it must outlive the caller that started it.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+		defer cancel()
+
+		return nil, r.fetch(ctx)
+	})
+	if err != nil {
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	key, ok, _ = r.lookup(kid)
+	return key, keyError(ok)
+}
+
+// lookup returns the cached key and whether the cache can answer without a
+// fetch, because it is fresh or a failed fetch is backing off.
+func (r *RemoteKeys) lookup(kid string) (key ed25519.PublicKey, ok bool, fresh bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok = r.keys[kid]
+	age := time.Since(r.fetched)
+	fresh = ok && age < r.ttl || !ok && age < minRefresh || time.Now().Before(r.retryAt)
+
+	return key, ok, fresh
+}
+
+func (r *RemoteKeys) fetch(ctx context.Context) error {
+	keys, err := r.get(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.retryAt = time.Now().Add(min(minRetry<<r.failures, maxRetry))
+		r.failures = min(r.failures+1, 16)
+		return err
+	}
+
+	r.keys = keys
+	r.fetched = time.Now()
+	r.failures = 0
+	r.retryAt = time.Time{}
+
+	return nil
+}
+
+func (r *RemoteKeys) get(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	res, err := r.client.GetKeys(ctx, &proto.GetKeysRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(res.GetKeys()))
+	for _, key := range res.GetKeys() {
+		if key.GetKty() != "OKP" || key.GetCrv() != "Ed25519" {
+			continue
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(key.GetX())
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s: malformed public key", key.GetKid())
+		}
+		keys[key.GetKid()] = x
+	}
+
+	return keys, nil
+}
+
+func keyError(ok bool) error {
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/alpha-omega-corp/cloud/app/user/pkg/proto"
+	"google.golang.org/grpc"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// keysClient serves GetKeys from a key set, or fails with err when set.
+type keysClient struct {
+	proto.UserServiceClient
+
+	keys    *KeySet
+	err     error
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (c *keysClient) GetKeys(ctx context.Context, _ *proto.GetKeysRequest, _ ...grpc.CallOption) (*proto.GetKeysResponse, error) {
+	c.calls.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c.keys.JWKS(), nil
+}
+
+func TestRemoteKeysSharesFetch(t *testing.T) {
+	client := &keysClient{keys: newKeySet(t, "a", "a"), release: make(chan struct{})}
+	remote := NewRemoteKeys(client, time.Minute)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := remote.PublicKey(context.Background(), "a")
+			errs <- err
+		}()
+	}
+
+	// Let the callers pile up on the pending fetch before answering it.
+	for client.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("public key: %v", err)
+		}
+	}
+	if calls := client.calls.Load(); calls != 1 {
+		t.Fatalf("fetched %d times, want 1", calls)
+	}
+}
+
+func TestRemoteKeysBacksOff(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	client := &keysClient{keys: newKeySet(t, "a", "a"), err: unavailable}
+	remote := NewRemoteKeys(client, time.Minute)
+
+	if _, err := remote.PublicKey(context.Background(), "a"); !errors.Is(err, unavailable) {
+		t.Fatalf("got %v, want %v", err, unavailable)
+	}
+	if _, err := remote.PublicKey(context.Background(), "a"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v during the backoff, want %v", err, ErrUnknownKey)
+	}
+	if calls := client.calls.Load(); calls != 1 {
+		t.Fatalf("fetched %d times during the backoff, want 1", calls)
+	}
+
+	remote.mu.Lock()
+	remote.retryAt = time.Time{}
+	remote.mu.Unlock()
+	client.err = nil
+
+	if _, err := remote.PublicKey(context.Background(), "a"); err != nil {
+		t.Fatalf("public key after the backoff: %v", err)
+	}
+}
@@ -3,69 +3,102 @@ package core
 import (
 	"context"
 	"embed"
-	"fmt"
+	"errors"
 	"github.com/alpha-omega-corp/cloud/core/config"
 	"github.com/alpha-omega-corp/cloud/core/database"
+	"github.com/alpha-omega-corp/cloud/core/health"
 	"github.com/alpha-omega-corp/cloud/core/httputils"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	"github.com/alpha-omega-corp/cloud/core/metrics"
+	"github.com/alpha-omega-corp/cloud/core/security"
 	srv "github.com/alpha-omega-corp/cloud/core/server"
+	"github.com/alpha-omega-corp/cloud/core/tracing"
 	"github.com/alpha-omega-corp/cloud/core/types"
 	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/dbfixture"
+	"github.com/uptrace/bun/extra/bunotel"
 	"github.com/uptrace/bun/migrate"
 	"github.com/uptrace/bunrouter"
-	"github.com/uptrace/bunrouter/extra/reqlog"
+	"github.com/uptrace/bunrouter/extra/bunrouterotel"
 	"github.com/urfave/cli/v3"
 	"google.golang.org/grpc"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 )
 
 type App struct {
-	name string
+	name      string
+	lifecycle *Lifecycle
+	health    *health.Checker
 
-	dbHandler *database.Handler
-	dbModels  []any
+	dbHandler  *database.Handler
+	dbModels   []any
+	migrations *migrate.Migrations
+
+	grpcOptions []srv.Option
+	reporters   []httputils.Reporter
 
 	config        *types.Config
 	configFS      embed.FS
+	configTarget  any
 	configHandler *config.Handler
 }
 
 func NewApp(efs embed.FS, name string) *App {
 	return &App{
 		name:      name,
+		lifecycle: NewLifecycle(DefaultDrainTimeout),
+		health:    health.NewChecker(),
 		configFS:  efs,
 		config:    nil,
 		dbHandler: nil,
 	}
 }
 
-func (app *App) CreateApi(init func(router *bunrouter.Router, configHandler *config.Handler)) os.Signal {
+func (app *App) Lifecycle() *Lifecycle {
+	return app.lifecycle
+}
+
+// Health returns the dependency checks backing the health endpoints.
+func (app *App) Health() *health.Checker {
+	return app.health
+}
+
+func (app *App) ConfigHandler() *config.Handler {
+	return app.configHandler
+}
+
+func (app *App) Config() *types.Config {
+	return app.config
+}
+
+// WithInterceptors adds service specific interceptors to the gRPC server,
+// they run after the ones provided by core.
+func (app *App) WithInterceptors(unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) *App {
+	app.grpcOptions = append(app.grpcOptions, srv.WithUnaryInterceptors(unary...), srv.WithStreamInterceptors(stream...))
+	return app
+}
+
+// WithReporter hands the panics recovered by the HTTP gateway to reporter.
+func (app *App) WithReporter(reporter httputils.Reporter) *App {
+	app.reporters = append(app.reporters, reporter)
+	return app
+}
+
+func (app *App) CreateApi(init func(router *bunrouter.Router, configHandler *config.Handler)) {
 	appCli := &cli.Command{
 		Usage: "cloud application cli",
 		Commands: []*cli.Command{
 			app.newHttpCommand(init),
+			app.configCommand(),
 		},
 	}
 
 	if err := appCli.Run(context.Background(), os.Args); err != nil {
-		log.Fatalf("app start error: %v\n", err)
+		logging.Fatal("app start error", "error", err)
 	}
-
-	// Create keyboard listener
-	ch := make(chan os.Signal, 3)
-	signal.Notify(
-		ch,
-		syscall.SIGINT,
-		syscall.SIGQUIT,
-		syscall.SIGTERM,
-	)
-
-	return <-ch
 }
 
 func (app *App) CreateApp(init func(config *types.Config, db *bun.DB, grpc *grpc.Server), models ...any) {
@@ -75,93 +108,263 @@ func (app *App) CreateApp(init func(config *types.Config, db *bun.DB, grpc *grpc
 		Usage: "cloud application cli",
 		Commands: []*cli.Command{
 			app.newGrpcCommand(init),
-			app.migrateCommand(),
+			app.dbCommand(),
+			app.configCommand(),
 		},
 	}
 
 	if err := appCli.Run(context.Background(), os.Args); err != nil {
-		log.Fatalf("app start error: %v\n", err)
+		logging.Fatal("app start error", "error", err)
 	}
 }
 
 func (app *App) newGrpcCommand(init func(config *types.Config, db *bun.DB, grpc *grpc.Server)) *cli.Command {
-	return app.createCommand("app", "server", func(ctx context.Context, cmd *cli.Command) {
-		if err := srv.NewGRPC(*app.config.Url, app.dbHandler, func(db *bun.DB, grpc *grpc.Server) {
-			init(app.config, db, grpc)
-			fmt.Printf("server start success\n")
-		}); err != nil {
+	return app.createCommand("app", "server", "start the gRPC server", func(ctx context.Context, cmd *cli.Command) {
+		advertise := app.advertise()
+
+		creds, err := security.ServerCredentials(app.config.TLS)
+		if err != nil {
+			logging.Fatal("grpc credentials error", "error", err)
+		}
+
+		opts := []srv.Option{
+			srv.WithHealth(app.health),
+			srv.WithCredentials(creds),
+			srv.WithMetrics(metrics.NewGRPC()),
+		}
+
+		if client := app.configHandler.Client(); client != nil {
+			opts = append(opts, srv.WithRegistry(client, app.name, advertise))
+		} else {
+			slog.Warn("service registry disabled, the configuration is kept in memory")
+		}
+
+		opts = append(opts, app.grpcOptions...)
+
+		grpcSrv, err := srv.NewGRPC(
+			*app.config.Url,
+			app.dbHandler,
+			func(db *bun.DB, grpc *grpc.Server) {
+				init(app.config, db, grpc)
+				slog.Info("server start success")
+			},
+			opts...,
+		)
+		if err != nil {
 			panic(err)
 		}
+
+		app.lifecycle.Append(Hook{
+			Name: "grpc",
+			OnStart: func(ctx context.Context) error {
+				if err := grpcSrv.Register(ctx); err != nil {
+					return err
+				}
+
+				go func() {
+					if err := grpcSrv.Serve(); err != nil {
+						slog.Error("grpc serve failed", "error", err)
+					}
+				}()
+				return nil
+			},
+			OnStop: grpcSrv.Shutdown,
+		})
+
+		if app.config.Metrics != nil {
+			app.serveMetrics(*app.config.Metrics)
+		}
+
+		app.run(ctx)
 	})
 }
 
 func (app *App) newHttpCommand(init func(router *bunrouter.Router, configHandler *config.Handler)) *cli.Command {
-	return app.createCommand("app", "server", func(ctx context.Context, cmd *cli.Command) {
+	return app.createCommand("app", "server", "start the HTTP gateway", func(ctx context.Context, cmd *cli.Command) {
 		r := bunrouter.New(
-			bunrouter.WithMiddleware(reqlog.NewMiddleware(
-				reqlog.WithEnabled(true),
-				reqlog.WithVerbose(true),
-			)))
+			bunrouter.WithMiddleware(bunrouterotel.NewMiddleware(
+				bunrouterotel.WithClientIP(),
+			)),
+			bunrouter.WithMiddleware(httputils.RequestLogger),
+			bunrouter.WithMiddleware(metrics.Middleware),
+			bunrouter.WithMiddleware(httputils.ErrorHandler),
+			bunrouter.WithMiddleware(httputils.Recover(app.reporters...)),
+		)
 
 		// Create clients
 		init(r, app.configHandler)
+		app.health.Register(r)
+		metrics.Register(r)
 
 		// Listen and serve
-		var handler http.Handler
-		handler = httputils.ExitOnPanicHandler{Next: r}
-
 		httpSrv := &http.Server{
 			Addr:         *app.config.Url,
 			ReadTimeout:  60 * time.Second,
 			WriteTimeout: 60 * time.Second,
 			IdleTimeout:  60 * time.Second,
-			Handler:      handler,
+			Handler:      r,
 		}
 
-		go func() {
-			if err := httpSrv.ListenAndServe(); err != nil && err.Error() != "http: Server closed" {
-				log.Printf("ListenAndServe failed: %s", err)
-			}
-		}()
+		app.lifecycle.Append(Hook{
+			Name: "http",
+			OnStart: func(ctx context.Context) error {
+				go func() {
+					if err := httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						slog.Error("http serve failed", "error", err)
+					}
+				}()
+
+				slog.Info("http server listening", "addr", httpSrv.Addr)
+				return nil
+			},
+			OnStop: httpSrv.Shutdown,
+		})
 
-		fmt.Printf("listening on http://%s\n", httpSrv.Addr)
+		app.run(ctx)
 	})
 }
 
-func (app *App) migrateCommand() *cli.Command {
-	return app.createCommand("db", "migration", func(ctx context.Context, cmd *cli.Command) {
-		db := app.dbHandler.Database()
+func (app *App) serveMetrics(addr string) {
+	metricsSrv := metrics.NewServer(addr)
 
-		migrator := migrate.NewMigrator(db, migrate.NewMigrations())
-		if err := migrator.Init(ctx); err != nil {
-			panic(err)
+	app.lifecycle.Append(Hook{
+		Name: "metrics",
+		OnStart: func(ctx context.Context) error {
+			go func() {
+				if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("metrics serve failed", "error", err)
+				}
+			}()
+
+			slog.Info("metrics server listening", "addr", metricsSrv.Addr, "path", metrics.Path)
+			return nil
+		},
+		OnStop: metricsSrv.Shutdown,
+	})
+}
+
+func (app *App) run(ctx context.Context) {
+	sig, err := app.lifecycle.Run(ctx)
+	if err != nil {
+		logging.Fatal("app lifecycle error", "error", err)
+	}
+
+	slog.Info("server stopped", "signal", sig)
+}
+
+// WithConfig registers a pointer to the typed configuration of the service.
+// It is decoded and validated when the application starts.
+func (app *App) WithConfig(target any) *App {
+	app.configTarget = target
+	return app
+}
+
+func (app *App) newConfigHandler(env string, kvs string) *config.Handler {
+	configFile, err := app.configFS.ReadFile(config.GetConfigPath(env))
+	if err != nil {
+		logging.Fatal("read config file error", "error", err)
+	}
+
+	return config.NewHandler(configFile, kvs)
+}
+
+func (app *App) loadConfig(env string, kvs string, name string, overrides []string) {
+	app.configHandler = app.newConfigHandler(env, kvs)
+	app.config = app.configHandler.LoadAs(context.Background(), name, overrides...)
+
+	if err := config.Validate(name, app.config); err != nil {
+		logging.Fatal("invalid configuration", "error", err)
+	}
+
+	if app.configTarget != nil {
+		if err := config.Decode(name, app.config, app.configTarget); err != nil {
+			logging.Fatal("invalid configuration", "error", err)
 		}
+	}
+}
 
-		if err := db.ResetModel(ctx, app.dbModels...); err != nil {
-			panic(err)
+func (app *App) advertise() string {
+	if app.config.Advertise != nil {
+		return *app.config.Advertise
+	}
+
+	return *app.config.Url
+}
+
+// loadTLS generates the development certificates of the service when the
+// configuration asks for it.
+func (app *App) loadTLS() {
+	if app.config.TLS == nil || !app.config.TLS.Dev {
+		return
+	}
+
+	var hosts []string
+	if host, _, err := net.SplitHostPort(app.advertise()); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+
+	if err := security.DevTLS(app.config.TLS, app.name, hosts...); err != nil {
+		logging.Fatal("dev tls error", "error", err)
+	}
+}
+
+func (app *App) newDatabaseHandler() *database.Handler {
+	opts := []database.Option{
+		database.WithQueryHook(logging.NewQueryHook()),
+		database.WithQueryHook(metrics.NewQueryHook()),
+		database.WithQueryHook(bunotel.NewQueryHook(bunotel.WithDBName(app.name))),
+	}
+
+	if c := app.config.Database; c != nil {
+		opts = append(opts, database.WithPool(database.Pool{
+			MaxOpenConns:    c.MaxOpenConns,
+			MaxIdleConns:    c.MaxIdleConns,
+			ConnMaxLifetime: c.ConnMaxLifetime,
+			ConnMaxIdleTime: c.ConnMaxIdleTime,
+		}))
+
+		if c.ReplicaDsn != nil {
+			opts = append(opts, database.WithReplica(*c.ReplicaDsn))
 		}
 
-		fixture := dbfixture.New(db)
-		if err := fixture.Load(ctx, os.DirFS("cmd/fixtures"), "fixture.yml"); err != nil {
-			fmt.Printf("load fixture error: %v\n", err)
-			panic(err)
+		if c.TLS != nil {
+			tlsConfig, err := security.ClientTLS(c.TLS)
+			if err != nil {
+				logging.Fatal("database tls error", "error", err)
+			}
+			opts = append(opts, database.WithTLS(tlsConfig))
 		}
-	})
+	}
+
+	return database.NewHandler(*app.config.Dsn, opts...)
 }
 
-func (app *App) loadConfig(env string, name string) {
-	configFile, err := app.configFS.ReadFile(config.GetConfigPath(env))
-	if err != nil {
-		log.Fatalf("read config file error: %v\n", err)
+// connectDatabase connects to the configured database, waiting for it up to
+// the connect timeout, and registers the models of the service.
+func (app *App) connectDatabase(ctx context.Context) {
+	app.dbHandler = app.newDatabaseHandler()
+	if err := app.dbHandler.Connect(ctx, app.connectTimeout()); err != nil {
+		logging.Fatal("database connect error", "error", err)
 	}
 
-	app.configHandler = config.NewHandler(configFile)
-	app.config = app.configHandler.LoadAs(context.Background(), name)
+	app.dbHandler.Database().RegisterModel(app.dbModels...)
 }
 
-func (app *App) createCommand(category string, name string, action func(ctx context.Context, cmd *cli.Command)) *cli.Command {
+func (app *App) connectTimeout() time.Duration {
+	if app.config.Database != nil && app.config.Database.ConnectTimeout > 0 {
+		return app.config.Database.ConnectTimeout
+	}
+
+	return database.DefaultConnectTimeout
+}
+
+// createCommand sets up everything a server needs before running action:
+// the configuration, logging, TLS, tracing, the health checks and the
+// database connection.
+func (app *App) createCommand(category string, name string, usage string, action func(ctx context.Context, cmd *cli.Command)) *cli.Command {
 	return &cli.Command{
 		Name:     name,
+		Usage:    usage,
 		Category: category,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
@@ -170,14 +373,58 @@ func (app *App) createCommand(category string, name string, action func(ctx cont
 				Value:   "local",
 				Usage:   "environment for configuration file",
 			},
+			kvsFlag(),
+			&cli.StringSliceFlag{
+				Name:  "set",
+				Usage: "override a configuration value, as key=value",
+			},
+			&cli.DurationFlag{
+				Name:  "drain-timeout",
+				Value: DefaultDrainTimeout,
+				Usage: "time allowed for in-flight requests to complete on shutdown",
+			},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			env := cmd.String("env")
-			app.loadConfig(env, app.name)
+			app.loadConfig(env, cmd.String("kvs"), app.name, cmd.StringSlice("set"))
+			if _, err := logging.Setup(app.name, app.config.Logging); err != nil {
+				logging.Fatal("logging setup error", "error", err)
+			}
+
+			app.loadTLS()
+			app.lifecycle.SetTimeout(cmd.Duration("drain-timeout"))
+
+			shutdownTracing, err := tracing.Setup(ctx, app.name, app.config.Tracing)
+			if err != nil {
+				logging.Fatal("tracing setup error", "error", err)
+			}
+
+			app.lifecycle.Append(Hook{
+				Name:   "tracing",
+				OnStop: shutdownTracing,
+			})
+
+			// The configuration is already loaded, an etcd outage only
+			// delays changes and must not stop the traffic.
+			app.health.AddOptional("kvs", app.configHandler.Ping)
+			app.lifecycle.Append(Hook{
+				Name: "config",
+				OnStop: func(ctx context.Context) error {
+					return app.configHandler.Close()
+				},
+			})
 
 			if app.config.Dsn != nil {
-				app.dbHandler = database.NewHandler(*app.config.Dsn)
-				app.dbHandler.Database().RegisterModel(app.dbModels...)
+				app.connectDatabase(ctx)
+				metrics.RegisterDB(app.dbHandler.Database().DB, app.name)
+				app.health.Add("postgres", app.dbHandler.Ping)
+
+				app.lifecycle.Append(Hook{
+					Name: "database",
+					OnStop: func(ctx context.Context) error {
+						return app.dbHandler.Close()
+					},
+				})
 			}
 
 			action(ctx, cmd)
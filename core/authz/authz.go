@@ -0,0 +1,243 @@
+package authz
+
+import (
+	"container/list"
+	"context"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/security"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long the permissions of a user are cached, changes to
+	// their roles take effect after at most this delay.
+	DefaultTTL = 30 * time.Second
+
+	// DefaultMaxEntries bounds the number of users whose permissions are
+	// cached, the least recently used are evicted first.
+	DefaultMaxEntries = 10000
+)
+
+// Matrix is the set of permissions granted to a user, keyed as
+// "<service>.read", "<service>.write" or "<service>.manage".
+type Matrix map[string]bool
+
+func (m Matrix) Allows(permission string) bool {
+	return m[permission]
+}
+
+// Identify returns the ID of the user making the call.
+type Identify func(ctx context.Context) (int64, error)
+
+// Source loads the permission matrix of a user.
+type Source func(ctx context.Context, userID int64) (Matrix, error)
+
+type entry struct {
+	userID  int64
+	matrix  Matrix
+	expires time.Time
+}
+
+type Authorizer struct {
+	identify   Identify
+	source     Source
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	lru     *list.List
+	cache   map[int64]*list.Element
+	trusted map[string]bool
+}
+
+type Option func(a *Authorizer)
+
+func WithTTL(ttl time.Duration) Option {
+	return func(a *Authorizer) {
+		a.ttl = ttl
+	}
+}
+
+func WithMaxEntries(n int) Option {
+	return func(a *Authorizer) {
+		a.maxEntries = n
+	}
+}
+
+// WithTrustedServices lists the services allowed to act for any user, by the
+// name of their mutual TLS certificate.
+func WithTrustedServices(names ...string) Option {
+	return func(a *Authorizer) {
+		a.trusted = trustedSet(names)
+	}
+}
+
+func NewAuthorizer(identify Identify, source Source, opts ...Option) *Authorizer {
+	a := &Authorizer{
+		identify:   identify,
+		source:     source,
+		ttl:        DefaultTTL,
+		maxEntries: DefaultMaxEntries,
+		lru:        list.New(),
+		cache:      make(map[int64]*list.Element),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Check returns a PermissionDenied error unless the caller was granted
+// permission, or the identification error when the caller is unknown.
+func (a *Authorizer) Check(ctx context.Context, permission string) error {
+	userID, err := a.identify(ctx)
+	if err != nil {
+		return err
+	}
+
+	matrix, err := a.Matrix(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !matrix.Allows(permission) {
+		return grpcutils.PermissionDenied("missing permission %s", permission)
+	}
+
+	return nil
+}
+
+// CheckSelf returns nil when the caller is a trusted service, the owner of
+// the resource, or was granted permission. Without a permission, only trusted
+// services and the owner are allowed. Other services, such as the gateway
+// relaying user calls, are checked as the user they act for.
+func (a *Authorizer) CheckSelf(ctx context.Context, owner int64, permission string) error {
+	if a.trustedPeer(ctx) {
+		return nil
+	}
+
+	userID, err := a.identify(ctx)
+	if err != nil {
+		return err
+	}
+
+	if userID == owner {
+		return nil
+	}
+
+	if permission == "" {
+		return grpcutils.PermissionDenied("user %d may only access their own resources", userID)
+	}
+
+	matrix, err := a.Matrix(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !matrix.Allows(permission) {
+		return grpcutils.PermissionDenied("missing permission %s", permission)
+	}
+
+	return nil
+}
+
+// SetTrustedServices replaces the services allowed to act for any user.
+func (a *Authorizer) SetTrustedServices(names ...string) {
+	trusted := trustedSet(names)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.trusted = trusted
+}
+
+func (a *Authorizer) trustedPeer(ctx context.Context) bool {
+	name, ok := security.PeerService(ctx)
+	if !ok {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.trusted[name]
+}
+
+func trustedSet(names []string) map[string]bool {
+	trusted := make(map[string]bool, len(names))
+	for _, name := range names {
+		trusted[name] = true
+	}
+
+	return trusted
+}
+
+// Matrix returns the permissions of the user, from the cache while fresh.
+func (a *Authorizer) Matrix(ctx context.Context, userID int64) (Matrix, error) {
+	if matrix, ok := a.cached(userID); ok {
+		return matrix, nil
+	}
+
+	matrix, err := a.source(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.store(userID, matrix)
+
+	return matrix, nil
+}
+
+// Invalidate drops the cached permissions of the user.
+func (a *Authorizer) Invalidate(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if el, ok := a.cache[userID]; ok {
+		a.remove(el)
+	}
+}
+
+func (a *Authorizer) cached(userID int64) (Matrix, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	el, ok := a.cache[userID]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !time.Now().Before(e.expires) {
+		a.remove(el)
+		return nil, false
+	}
+
+	a.lru.MoveToFront(el)
+	return e.matrix, true
+}
+
+func (a *Authorizer) store(userID int64, matrix Matrix) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e := &entry{userID: userID, matrix: matrix, expires: time.Now().Add(a.ttl)}
+	if el, ok := a.cache[userID]; ok {
+		el.Value = e
+		a.lru.MoveToFront(el)
+		return
+	}
+
+	a.cache[userID] = a.lru.PushFront(e)
+	for a.maxEntries > 0 && a.lru.Len() > a.maxEntries {
+		a.remove(a.lru.Back())
+	}
+}
+
+func (a *Authorizer) remove(el *list.Element) {
+	a.lru.Remove(el)
+	delete(a.cache, el.Value.(*entry).userID)
+}
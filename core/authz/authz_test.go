@@ -0,0 +1,141 @@
+package authz
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type callerKey struct{}
+
+func newAuthorizer(loads *int, opts ...Option) *Authorizer {
+	return NewAuthorizer(
+		func(ctx context.Context) (int64, error) {
+			userID, ok := ctx.Value(callerKey{}).(int64)
+			if !ok {
+				return 0, status.Error(codes.Unauthenticated, "missing token")
+			}
+			return userID, nil
+		},
+		func(ctx context.Context, userID int64) (Matrix, error) {
+			*loads++
+			if userID == 1 {
+				return Matrix{"user.read": true}, nil
+			}
+			return Matrix{}, nil
+		},
+		opts...,
+	)
+}
+
+func as(userID int64) context.Context {
+	return context.WithValue(context.Background(), callerKey{}, userID)
+}
+
+// from returns ctx as seen by a server whose caller presented a verified
+// client certificate named service.
+func from(ctx context.Context, service string) context.Context {
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: service}}
+
+	return peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}},
+		},
+	})
+}
+
+func TestMatrixCache(t *testing.T) {
+	var loads int
+	a := newAuthorizer(&loads, WithMaxEntries(2))
+	ctx := context.Background()
+
+	for _, userID := range []int64{1, 1, 2, 1, 3, 1, 2} {
+		if _, err := a.Matrix(ctx, userID); err != nil {
+			t.Fatalf("matrix: %v", err)
+		}
+	}
+
+	// 1 stays cached as the most recently used, 2 is evicted by 3.
+	if loads != 4 {
+		t.Fatalf("got %d loads, want 4", loads)
+	}
+	if len(a.cache) != 2 || a.lru.Len() != 2 {
+		t.Fatalf("cache holds %d entries, want 2", len(a.cache))
+	}
+}
+
+func TestMatrixCacheExpires(t *testing.T) {
+	var loads int
+	a := newAuthorizer(&loads, WithTTL(time.Millisecond))
+	ctx := context.Background()
+
+	_, _ = a.Matrix(ctx, 1)
+	time.Sleep(5 * time.Millisecond)
+	_, _ = a.Matrix(ctx, 1)
+
+	if loads != 2 {
+		t.Fatalf("got %d loads, want the expired entry reloaded", loads)
+	}
+}
+
+func TestCheckSelf(t *testing.T) {
+	var loads int
+	a := newAuthorizer(&loads)
+
+	for _, tt := range []struct {
+		name       string
+		ctx        context.Context
+		owner      int64
+		permission string
+		code       codes.Code
+	}{
+		{"anonymous", context.Background(), 2, "user.read", codes.Unauthenticated},
+		{"owner", as(2), 2, "", codes.OK},
+		{"other user", as(2), 3, "user.read", codes.PermissionDenied},
+		{"other user without permission", as(1), 3, "", codes.PermissionDenied},
+		{"granted", as(1), 3, "user.read", codes.OK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.CheckSelf(tt.ctx, tt.owner, tt.permission)
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("got %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestCheckSelfTrustedServices(t *testing.T) {
+	var loads int
+	a := newAuthorizer(&loads, WithTrustedServices("docker"))
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{"trusted service", from(context.Background(), "docker"), codes.OK},
+		{"untrusted service", from(context.Background(), "gateway"), codes.Unauthenticated},
+		{"gateway relaying the owner", from(as(3), "gateway"), codes.OK},
+		{"gateway relaying another user", from(as(2), "gateway"), codes.PermissionDenied},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.CheckSelf(tt.ctx, 3, "user.read")
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("got %s, want %s", got, tt.code)
+			}
+		})
+	}
+
+	a.SetTrustedServices()
+	err := a.CheckSelf(from(context.Background(), "docker"), 3, "user.read")
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Fatalf("service removed from the trusted list: got %s, want %s", got, codes.Unauthenticated)
+	}
+}
@@ -0,0 +1,71 @@
+package authz
+
+import (
+	"context"
+	"google.golang.org/grpc"
+)
+
+// Policy maps the full name of gRPC methods to the permission they require.
+// Methods missing from the policy are not checked.
+type Policy map[string]string
+
+// SelfPolicy maps the full name of gRPC methods whose requests belong to a
+// user, to the rule deciding who may call them.
+type SelfPolicy map[string]Self
+
+// Self restricts a method to trusted services and to the user owning the
+// request. Other users need Permission, when set.
+type Self struct {
+	Owner      func(req any) int64
+	Permission string
+}
+
+func (a *Authorizer) UnaryInterceptor(policy Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if permission, ok := policy[info.FullMethod]; ok {
+			if err := a.Check(ctx, permission); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// SelfInterceptor enforces policy on unary calls, see CheckSelf.
+func (a *Authorizer) SelfInterceptor(policy SelfPolicy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if rule, ok := policy[info.FullMethod]; ok {
+			if err := a.CheckSelf(ctx, rule.Owner(req), rule.Permission); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authorizer) StreamInterceptor(policy Policy) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if permission, ok := policy[info.FullMethod]; ok {
+			if err := a.Check(ss.Context(), permission); err != nil {
+				return err
+			}
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// Interceptors returns the unary and stream interceptors enforcing policy,
+// in the shape expected by core.App.WithInterceptors.
+func (a *Authorizer) Interceptors(policy Policy) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	return []grpc.UnaryServerInterceptor{a.UnaryInterceptor(policy)},
+		[]grpc.StreamServerInterceptor{a.StreamInterceptor(policy)}
+}
+
+// Method returns the full name of the named method of service, as found in
+// a Policy.
+func Method(service grpc.ServiceDesc, name string) string {
+	return "/" + service.ServiceName + "/" + name
+}
@@ -0,0 +1,26 @@
+package authz
+
+import (
+	"github.com/alpha-omega-corp/cloud/core/httputils"
+	"github.com/uptrace/bunrouter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// Require answers 403 to the requests whose caller was not granted
+// permission. It runs after the middleware authenticating the caller.
+func (a *Authorizer) Require(permission string) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			if err := a.Check(req.Context(), permission); err != nil {
+				if status.Code(err) == codes.PermissionDenied {
+					return httputils.Forbidden("missing permission %s", permission)
+				}
+				return err
+			}
+
+			return next(w, req)
+		}
+	}
+}
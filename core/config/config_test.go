@@ -0,0 +1,285 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const embedded = `
+url: localhost:50051
+metrics: localhost:9051
+secret: embedded
+`
+
+type serviceConfig struct {
+	Secret  string        `mapstructure:"secret" validate:"required"`
+	Timeout time.Duration `mapstructure:"timeout" default:"5s"`
+	Url     string        `mapstructure:"url" validate:"hostport"`
+}
+
+func newHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	h := NewHandler([]byte(embedded), MemoryKVS)
+	t.Cleanup(func() {
+		_ = h.Close()
+	})
+
+	return h
+}
+
+func TestLoadAsLayers(t *testing.T) {
+	h := newHandler(t)
+	ctx := context.Background()
+
+	if err := h.kv.Put(ctx, key("svc"), []byte("url: remote:50051\nmetrics: remote:9051\n")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	t.Setenv("SVC_METRICS", "env:9051")
+
+	cfg := h.LoadAs(ctx, "svc", "secret=flag")
+
+	if got := *cfg.Url; got != "remote:50051" {
+		t.Errorf("url: got %q, want the etcd value", got)
+	}
+	if got := *cfg.Metrics; got != "env:9051" {
+		t.Errorf("metrics: got %q, want the environment value", got)
+	}
+	if got := cfg.Env.GetString("secret"); got != "flag" {
+		t.Errorf("secret: got %q, want the command line value", got)
+	}
+}
+
+func TestLoadAsWritesNothing(t *testing.T) {
+	h := newHandler(t)
+	ctx := context.Background()
+
+	h.LoadAs(ctx, "svc")
+
+	stored, err := h.kv.Get(ctx, key("svc"))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored != nil {
+		t.Fatalf("stored %q, want etcd left to the operator overrides", stored)
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	h := newHandler(t)
+	ctx := context.Background()
+
+	if err := h.kv.Put(ctx, key("svc"), []byte("secret: rotated\npassphrase: hunter2\nurl: remote:50051\n")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	changes, err := h.Diff(ctx, "svc")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	redactor := &types.Config{Secrets: SecretKeys(&struct {
+		Passphrase types.Secret `mapstructure:"passphrase"`
+	}{})}
+
+	var out []string
+	for _, change := range changes {
+		out = append(out, change.Redact(redactor).String())
+	}
+
+	want := []string{
+		"- metrics: localhost:9051",
+		"+ passphrase: [REDACTED]",
+		"~ secret: [REDACTED] -> [REDACTED]",
+		"~ url: localhost:50051 -> remote:50051",
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+}
+
+func TestWatchAs(t *testing.T) {
+	h := newHandler(t)
+	ctx := context.Background()
+	h.LoadAs(ctx, "svc")
+
+	updates := make(chan *serviceConfig, 1)
+	WatchAs(h, "svc", func(c *serviceConfig) {
+		updates <- c
+	})
+
+	// Invalid versions are skipped.
+	if err := h.kv.Put(ctx, key("svc"), []byte("url: nohostport\n")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := h.kv.Put(ctx, key("svc"), []byte("secret: rotated\n")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	select {
+	case c := <-updates:
+		if c.Secret != "rotated" {
+			t.Fatalf("got secret %q, want rotated", c.Secret)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no configuration update")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	h := newHandler(t)
+	cfg := h.LoadAs(context.Background(), "svc")
+
+	target := new(serviceConfig)
+	if err := Decode("svc", cfg, target); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	want := &serviceConfig{Secret: "embedded", Timeout: 5 * time.Second, Url: "localhost:50051"}
+	if !reflect.DeepEqual(target, want) {
+		t.Fatalf("got %+v, want %+v", target, want)
+	}
+}
+
+func TestDecodeLeavesEnvUntouched(t *testing.T) {
+	h := newHandler(t)
+	t.Setenv("SVC_TIMEOUT", "7s")
+	cfg := h.LoadAs(context.Background(), "svc")
+
+	target := new(serviceConfig)
+	if err := Decode("svc", cfg, target); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if target.Timeout != 7*time.Second {
+		t.Errorf("timeout: got %s, want the environment value", target.Timeout)
+	}
+	if _, ok := cfg.Env.AllSettings()["timeout"]; ok {
+		t.Errorf("decode added the timeout default to the shared configuration")
+	}
+}
+
+func TestDecodeRedactsSecrets(t *testing.T) {
+	h := newHandler(t)
+	cfg := h.LoadAs(context.Background(), "svc", "private.k1=pem", "passphrase=hunter2")
+
+	target := new(struct {
+		Private    map[string]types.Secret `mapstructure:"private"`
+		Passphrase types.Secret            `mapstructure:"passphrase"`
+	})
+	if err := Decode("svc", cfg, target); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	s := cfg.String()
+	if strings.Contains(s, "pem") || strings.Contains(s, "hunter2") {
+		t.Fatalf("%s prints secret fields", s)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate("svc", &serviceConfig{Url: "localhost"})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+
+	want := []string{"secret: is required", `url: malformed host:port "localhost"`}
+	if !reflect.DeepEqual(validationErr.Problems, want) {
+		t.Fatalf("got %v, want %v", validationErr.Problems, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	keyFile := filepath.Join(dir, "key")
+	if err := GenerateKey(keyFile); err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	secretFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	t.Setenv("TEST_SECRET", "from-env")
+
+	r := NewResolver(NewMemoryKV(), keyFile)
+	if err := r.Seal(ctx, "secrets/db", "from-etcd"); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	for ref, want := range map[string]string{
+		"plain":                      "plain",
+		"env://TEST_SECRET":          "from-env",
+		"secret://file" + secretFile: "from-file",
+		"secret://etcd/secrets/db":   "from-etcd",
+	} {
+		got, err := r.Resolve(ctx, ref)
+		if err != nil {
+			t.Errorf("resolve %s: %v", ref, err)
+		} else if got != want {
+			t.Errorf("resolve %s: got %q, want %q", ref, got, want)
+		}
+	}
+
+	if _, err := r.Resolve(ctx, "env://TEST_MISSING"); err == nil {
+		t.Error("resolved a missing environment variable")
+	}
+
+	if _, err := NewResolver(r.kv, "").Resolve(ctx, "secret://etcd/secrets/db"); err == nil {
+		t.Error("resolved an etcd secret without the key file")
+	}
+}
+
+func TestMergeOtherService(t *testing.T) {
+	h := newHandler(t)
+	h.LoadAs(context.Background(), "svc", "secret=flag")
+
+	cfg, err := h.merge("other", []byte("url: other:50051\n"))
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	// Only the remote entry of another service is read.
+	if got := cfg.Env.GetString("url"); got != "other:50051" {
+		t.Errorf("url: got %q, want the etcd value", got)
+	}
+	if cfg.Env.IsSet("secret") {
+		t.Errorf("secret: got %q, want no local layers", cfg.Env.GetString("secret"))
+	}
+}
+
+func TestMergeInvalidOverride(t *testing.T) {
+	h := newHandler(t)
+	h.name = "svc"
+	h.overrides = []string{"secret"}
+
+	if _, err := h.merge("svc", nil); err == nil {
+		t.Fatal("merged an override without a value")
+	}
+}
+
+func TestLoadAsRedactsSecrets(t *testing.T) {
+	h := newHandler(t)
+	t.Setenv("TEST_TOKEN", "hunter2")
+
+	cfg := h.LoadAs(context.Background(), "svc", "token=env://TEST_TOKEN")
+
+	if got := cfg.Env.GetString("token"); got != "hunter2" {
+		t.Fatalf("token: got %q, want the resolved value", got)
+	}
+	if s := cfg.String(); strings.Contains(s, "hunter2") {
+		t.Fatalf("%s prints the resolved secret", s)
+	}
+}
@@ -3,22 +3,27 @@ package config
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"github.com/alpha-omega-corp/cloud/core/types"
 	"github.com/spf13/viper"
-	_ "github.com/spf13/viper/remote"
 	clientv3 "go.etcd.io/etcd/client/v3"
-	"time"
+	"log/slog"
 )
 
 type Handler struct {
 	viper         *viper.Viper
-	etcd          *clientv3.Client
+	kv            KV
 	initialConfig []byte
-	host          string
+
+	name      string
+	overrides []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewHandler(file []byte) *Handler {
+// NewHandler connects to the key-value store at kvs, or to the one named by
+// the kvs key of the configuration file when empty. See NewKV.
+func NewHandler(file []byte, kvs string) *Handler {
 	v := viper.New()
 
 	v.SetConfigType("yaml")
@@ -27,35 +32,59 @@ func NewHandler(file []byte) *Handler {
 		panic(err)
 	}
 
-	host := v.GetString("kvs")
-
-	fmt.Printf("config host > %s\n", host)
-
-	config := clientv3.Config{
-		Endpoints:   []string{host},
-		DialTimeout: 5 * time.Second,
+	if kvs == "" {
+		kvs = v.GetString("kvs")
 	}
 
-	etcd, err := clientv3.New(config)
+	slog.Info("config host", "host", kvs)
+
+	kv, err := NewKV(kvs)
 	if err != nil {
 		panic(err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Handler{
-		host:          host,
-		etcd:          etcd,
+		kv:            kv,
 		initialConfig: file,
 		viper:         viper.New(),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 }
 
-func (h *Handler) LoadAs(ctx context.Context, name string) (config *types.Config) {
-	_, err := h.etcd.Put(ctx, "config_"+name, string(h.initialConfig))
+// Client returns the etcd client backing the handler, nil when the
+// configuration is kept in memory.
+func (h *Handler) Client() *clientv3.Client {
+	if etcd, ok := h.kv.(*EtcdKV); ok {
+		return etcd.Client()
+	}
+
+	return nil
+}
+
+// Ping checks that the key-value store answers reads.
+func (h *Handler) Ping(ctx context.Context) error {
+	_, err := h.kv.Get(ctx, "health")
+	return err
+}
+
+// LoadAs resolves the configuration of the named service. Values are layered
+// in order of precedence: embedded defaults, etcd overrides, environment
+// variables prefixed with the service name, then key=value overrides coming
+// from the command line. The etcd entry only holds the values operators
+// override, nothing is written to it unless the configuration is pushed.
+func (h *Handler) LoadAs(ctx context.Context, name string, overrides ...string) (config *types.Config) {
+	h.name = name
+	h.overrides = overrides
+
+	remote, err := h.get(ctx, name)
 	if err != nil {
 		panic(err)
 	}
 
-	cfg, err := h.GetConfig(name)
+	cfg, err := h.merge(name, remote)
 	if err != nil {
 		panic(err)
 	}
@@ -85,13 +114,50 @@ func (h *Handler) GetConfig(name string) (config *types.Config, err error) {
 func (h *Handler) Read(key string, format string) (err error) {
 	h.viper = viper.New()
 
-	err = h.viper.AddRemoteProvider("etcd3", "http://"+h.host, key)
+	data, err := h.kv.Get(h.ctx, key)
 	if err != nil {
 		return
 	}
 
 	h.viper.SetConfigType(format)
-	err = h.viper.ReadRemoteConfig()
+	err = h.viper.ReadConfig(bytes.NewBuffer(data))
 
 	return
 }
+
+// Watch calls fn with the new configuration every time the stored entry of
+// the named service changes, until the handler is closed.
+func (h *Handler) Watch(name string, fn func(config *types.Config)) {
+	ch := h.kv.Watch(h.ctx, key(name))
+
+	go func() {
+		for value := range ch {
+			cfg, err := h.merge(name, value)
+			if err != nil {
+				slog.Error("config watch failed", "name", name, "error", err)
+				continue
+			}
+
+			fn(cfg)
+		}
+	}()
+}
+
+func (h *Handler) Close() error {
+	h.cancel()
+	return h.kv.Close()
+}
+
+// WatchAs decodes and validates every new version of the named configuration
+// into T before calling fn. Invalid versions are reported and skipped.
+func WatchAs[T any](h *Handler, name string, fn func(config *T)) {
+	h.Watch(name, func(config *types.Config) {
+		target := new(T)
+		if err := Decode(name, config, target); err != nil {
+			slog.Error("config watch failed", "name", name, "error", err)
+			return
+		}
+
+		fn(target)
+	})
+}
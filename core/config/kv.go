@@ -0,0 +1,182 @@
+package config
+
+import (
+	"context"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// MemoryKVS selects the in-process store instead of etcd, for tests and
+// single binary local runs. Nothing is shared with other processes.
+const MemoryKVS = "memory"
+
+// KV is the key-value store holding the configurations and sealed secrets.
+// Get returns nil when the key does not exist. Watch sends the new value of
+// the key after every Put until ctx is done.
+type KV interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte) error
+	Watch(ctx context.Context, key string) <-chan []byte
+	Close() error
+}
+
+// NewKV returns the in-memory store for MemoryKVS, and an etcd client
+// connected to the host:port address otherwise.
+func NewKV(host string) (KV, error) {
+	if host == MemoryKVS {
+		return NewMemoryKV(), nil
+	}
+
+	return NewEtcdKV(host)
+}
+
+type EtcdKV struct {
+	client *clientv3.Client
+}
+
+var _ KV = (*EtcdKV)(nil)
+
+func NewEtcdKV(host string) (*EtcdKV, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{host},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EtcdKV{client: client}, nil
+}
+
+func (kv *EtcdKV) Client() *clientv3.Client {
+	return kv.client
+}
+
+func (kv *EtcdKV) Get(ctx context.Context, key string) ([]byte, error) {
+	res, err := kv.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Kvs) == 0 {
+		return nil, nil
+	}
+
+	return res.Kvs[0].Value, nil
+}
+
+func (kv *EtcdKV) Put(ctx context.Context, key string, value []byte) error {
+	_, err := kv.client.Put(ctx, key, string(value))
+	return err
+}
+
+func (kv *EtcdKV) Watch(ctx context.Context, key string) <-chan []byte {
+	ch := make(chan []byte)
+
+	go func() {
+		defer close(ch)
+
+		for res := range kv.client.Watch(ctx, key) {
+			if err := res.Err(); err != nil {
+				slog.Error("kv watch failed", "key", key, "error", err)
+				continue
+			}
+
+			for _, event := range res.Events {
+				if event.Type != clientv3.EventTypePut {
+					continue
+				}
+
+				select {
+				case ch <- event.Kv.Value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (kv *EtcdKV) Close() error {
+	return kv.client.Close()
+}
+
+type MemoryKV struct {
+	mu       sync.Mutex
+	values   map[string][]byte
+	watchers map[string][]chan []byte
+}
+
+var _ KV = (*MemoryKV)(nil)
+
+func NewMemoryKV() *MemoryKV {
+	return &MemoryKV{
+		values:   make(map[string][]byte),
+		watchers: make(map[string][]chan []byte),
+	}
+}
+
+func (kv *MemoryKV) Get(_ context.Context, key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	value, ok := kv.values[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+// Put stores value and hands it to the watchers of key. A watcher that has
+// not consumed the previous value only receives the latest one.
+func (kv *MemoryKV) Put(_ context.Context, key string, value []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.values[key] = append([]byte(nil), value...)
+
+	for _, ch := range kv.watchers[key] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- append([]byte(nil), value...)
+	}
+
+	return nil
+}
+
+func (kv *MemoryKV) Watch(ctx context.Context, key string) <-chan []byte {
+	ch := make(chan []byte, 1)
+
+	kv.mu.Lock()
+	kv.watchers[key] = append(kv.watchers[key], ch)
+	kv.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		kv.mu.Lock()
+		defer kv.mu.Unlock()
+
+		watchers := kv.watchers[key]
+		for i, w := range watchers {
+			if w == ch {
+				kv.watchers[key] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (kv *MemoryKV) Close() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+func key(name string) string {
+	return "config_" + name
+}
+
+func (h *Handler) get(ctx context.Context, name string) ([]byte, error) {
+	return h.kv.Get(ctx, key(name))
+}
+
+// merge layers the remote configuration over the embedded one. Environment
+// variables and command line overrides only apply to the service the handler
+// was loaded as.
+func (h *Handler) merge(name string, remote []byte) (*types.Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	local := name == h.name
+	if local {
+		if err := v.ReadConfig(bytes.NewBuffer(h.initialConfig)); err != nil {
+			return nil, err
+		}
+	}
+
+	if remote != nil {
+		if err := v.MergeConfig(bytes.NewBuffer(remote)); err != nil {
+			return nil, err
+		}
+	}
+
+	if local {
+		v.SetEnvPrefix(name)
+		v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+		v.AutomaticEnv()
+
+		for _, override := range h.overrides {
+			k, val, ok := strings.Cut(override, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid override %q, expected key=value", override)
+			}
+			v.Set(strings.TrimSpace(k), strings.TrimSpace(val))
+		}
+	}
+
+	secrets, err := NewResolver(h.kv, v.GetString(KeyFileKey)).ResolveAll(h.ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg *types.Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.Env = v
+	cfg.Secrets = secrets
+	return cfg, nil
+}
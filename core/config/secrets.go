@@ -0,0 +1,186 @@
+package config
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+const (
+	schemeSecret = "secret://"
+	schemeEnv    = "env://"
+
+	// KeyFileKey is the configuration key holding the path of the local key
+	// used to decrypt secrets stored in etcd.
+	KeyFileKey = "secrets.key_file"
+)
+
+// Resolver expands secret references found in configuration values:
+//
+//	env://VAR              value of the environment variable VAR
+//	secret://file/path     content of the file at /path
+//	secret://etcd/key      entry of the key-value store encrypted with the local key file
+type Resolver struct {
+	kv      KV
+	keyFile string
+}
+
+func NewResolver(kv KV, keyFile string) *Resolver {
+	return &Resolver{
+		kv:      kv,
+		keyFile: keyFile,
+	}
+}
+
+func (h *Handler) Resolver(keyFile string) *Resolver {
+	return NewResolver(h.kv, keyFile)
+}
+
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, schemeSecret) || strings.HasPrefix(value, schemeEnv)
+}
+
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	if name, ok := strings.CutPrefix(ref, schemeEnv); ok {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	}
+
+	location, ok := strings.CutPrefix(ref, schemeSecret)
+	if !ok {
+		return ref, nil
+	}
+
+	provider, path, _ := strings.Cut(location, "/")
+	switch provider {
+	case "file":
+		data, err := os.ReadFile("/" + path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	case "etcd":
+		return r.resolveEtcd(ctx, path)
+	}
+
+	return "", fmt.Errorf("unknown secret provider %q", provider)
+}
+
+// ResolveAll replaces every reference of v by its value and returns the keys
+// that were resolved.
+func (r *Resolver) ResolveAll(ctx context.Context, v *viper.Viper) ([]string, error) {
+	var keys []string
+	for _, key := range v.AllKeys() {
+		value, ok := v.Get(key).(string)
+		if !ok || !IsReference(value) {
+			continue
+		}
+
+		resolved, err := r.Resolve(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", key, err)
+		}
+
+		v.Set(key, resolved)
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Seal encrypts value with the local key file and stores it in the key-value
+// store, where it can be referenced as secret://etcd/<key>.
+func (r *Resolver) Seal(ctx context.Context, key string, value string) error {
+	aead, err := r.cipher()
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(key))
+	return r.kv.Put(ctx, key, []byte(base64.StdEncoding.EncodeToString(sealed)))
+}
+
+func (r *Resolver) resolveEtcd(ctx context.Context, key string) (string, error) {
+	if r.kv == nil {
+		return "", errors.New("no key-value store available")
+	}
+
+	value, err := r.kv.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	if value == nil {
+		return "", fmt.Errorf("secret %s not found", key)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(value))
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := r.cipher()
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("secret %s is malformed", key)
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret %s: %w", key, err)
+	}
+
+	return string(plain), nil
+}
+
+func (r *Resolver) cipher() (cipher.AEAD, error) {
+	if r.keyFile == "" {
+		return nil, fmt.Errorf("no key file configured, set %s", KeyFileKey)
+	}
+
+	encoded, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", r.keyFile, err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// GenerateKey writes a new random AES-256 key, base64 encoded, to path.
+func GenerateKey(path string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0600)
+}
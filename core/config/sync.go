@@ -0,0 +1,133 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/spf13/viper"
+	"reflect"
+	"sort"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "+"
+	ChangeRemoved  ChangeType = "-"
+	ChangeModified ChangeType = "~"
+)
+
+// Change describes a key whose value differs between the embedded
+// configuration and the one stored in etcd.
+type Change struct {
+	Type     ChangeType
+	Key      string
+	Embedded any
+	Remote   any
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("%s %s: %v", c.Type, c.Key, c.Remote)
+	case ChangeRemoved:
+		return fmt.Sprintf("%s %s: %v", c.Type, c.Key, c.Embedded)
+	default:
+		return fmt.Sprintf("%s %s: %v -> %v", c.Type, c.Key, c.Embedded, c.Remote)
+	}
+}
+
+// Redact hides the values of the change that the configuration marks as
+// secret, see types.Config.Redact.
+func (c Change) Redact(config *types.Config) Change {
+	if c.Embedded != nil {
+		c.Embedded = config.Redact(c.Key, c.Embedded)
+	}
+	if c.Remote != nil {
+		c.Remote = config.Redact(c.Key, c.Remote)
+	}
+
+	return c
+}
+
+// Push overwrites the etcd entry of the named service with the embedded file.
+func (h *Handler) Push(ctx context.Context, name string) error {
+	return h.kv.Put(ctx, key(name), h.initialConfig)
+}
+
+// Pull returns the raw configuration stored in etcd for the named service.
+func (h *Handler) Pull(ctx context.Context, name string) ([]byte, error) {
+	remote, err := h.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote == nil {
+		return nil, fmt.Errorf("no configuration stored for %s", name)
+	}
+
+	return remote, nil
+}
+
+// Diff lists the keys that differ between the embedded file and etcd.
+// Added keys only exist in etcd, removed keys only exist in the embedded file.
+func (h *Handler) Diff(ctx context.Context, name string) ([]Change, error) {
+	remote, err := h.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	embedded, err := settings(h.initialConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := settings(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for k, value := range embedded {
+		remoteValue, ok := stored[k]
+		if !ok {
+			changes = append(changes, Change{Type: ChangeRemoved, Key: k, Embedded: value})
+			continue
+		}
+
+		if !reflect.DeepEqual(value, remoteValue) {
+			changes = append(changes, Change{Type: ChangeModified, Key: k, Embedded: value, Remote: remoteValue})
+		}
+	}
+
+	for k, value := range stored {
+		if _, ok := embedded[k]; !ok {
+			changes = append(changes, Change{Type: ChangeAdded, Key: k, Remote: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes, nil
+}
+
+func settings(data []byte) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	if data != nil {
+		if err := v.ReadConfig(bytes.NewBuffer(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make(map[string]any)
+	for _, k := range v.AllKeys() {
+		values[k] = v.Get(k)
+	}
+
+	return values, nil
+}
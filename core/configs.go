@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/config"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"github.com/urfave/cli/v3"
+	"os"
+)
+
+func (app *App) configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "manage the configuration stored in etcd",
+		Commands: []*cli.Command{
+			app.createConfigCommand("push", "overwrite the etcd configuration with the embedded file", func(ctx context.Context, cmd *cli.Command, h *config.Handler) error {
+				if err := h.Push(ctx, app.name); err != nil {
+					return err
+				}
+
+				fmt.Printf("pushed configuration for %s\n", app.name)
+				return nil
+			}),
+			app.createConfigCommand("pull", "print the configuration stored in etcd", func(ctx context.Context, cmd *cli.Command, h *config.Handler) error {
+				data, err := h.Pull(ctx, app.name)
+				if err != nil {
+					return err
+				}
+
+				if output := cmd.String("output"); output != "" {
+					return os.WriteFile(output, data, 0644)
+				}
+
+				fmt.Printf("%s\n", data)
+				return nil
+			}, &cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "write the configuration to a file instead of stdout",
+			}),
+			app.createConfigCommand("diff", "compare the embedded file with the etcd configuration", func(ctx context.Context, cmd *cli.Command, h *config.Handler) error {
+				changes, err := h.Diff(ctx, app.name)
+				if err != nil {
+					return err
+				}
+
+				if len(changes) == 0 {
+					fmt.Printf("configuration for %s is up to date\n", app.name)
+					return nil
+				}
+
+				redactor := &types.Config{Secrets: config.SecretKeys(app.configTarget)}
+				for _, change := range changes {
+					fmt.Println(change.Redact(redactor))
+				}
+				return nil
+			}),
+			app.createConfigCommand("seal", "encrypt a secret and store it in etcd", func(ctx context.Context, cmd *cli.Command, h *config.Handler) error {
+				if cmd.Args().Len() != 2 {
+					return fmt.Errorf("usage: config seal <key> <value>")
+				}
+
+				key := cmd.Args().Get(0)
+				if err := h.Resolver(cmd.String("key-file")).Seal(ctx, key, cmd.Args().Get(1)); err != nil {
+					return err
+				}
+
+				fmt.Printf("sealed secret, reference it as secret://etcd/%s\n", key)
+				return nil
+			}, &cli.StringFlag{
+				Name:     "key-file",
+				Usage:    "path of the key used to encrypt secrets",
+				Required: true,
+			}),
+			{
+				Name:      "keygen",
+				Usage:     "generate a key file used to seal secrets",
+				ArgsUsage: "<path>",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					path := cmd.Args().First()
+					if path == "" {
+						return fmt.Errorf("usage: config keygen <path>")
+					}
+
+					return config.GenerateKey(path)
+				},
+			},
+		},
+	}
+}
+
+func (app *App) createConfigCommand(name string, usage string, action func(ctx context.Context, cmd *cli.Command, h *config.Handler) error, flags ...cli.Flag) *cli.Command {
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "env",
+				Aliases: []string{"e"},
+				Value:   "local",
+				Usage:   "environment for configuration file",
+			},
+			kvsFlag(),
+		}, flags...),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			h := app.newConfigHandler(cmd.String("env"), cmd.String("kvs"))
+			defer h.Close()
+
+			return action(ctx, cmd, h)
+		},
+	}
+}
+
+func kvsFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "kvs",
+		Usage: "key-value store holding the configuration, etcd host:port or " + config.MemoryKVS + ", defaults to the kvs key of the configuration file",
+	}
+}
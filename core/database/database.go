@@ -1,44 +1,180 @@
 package database
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
+	"fmt"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
-	"github.com/uptrace/bun/extra/bundebug"
+	"github.com/uptrace/bun/extra/bunexp"
+	"log/slog"
+	"net/url"
 	"sync"
+	"time"
+)
+
+const (
+	DefaultConnectTimeout = 30 * time.Second
+
+	minBackoff = 250 * time.Millisecond
+	maxBackoff = 5 * time.Second
 )
 
 type Handler struct {
 	dbOnce sync.Once
 	db     *bun.DB
 
-	dsn string
+	dsn        string
+	replicaDsn string
+	tls        *tls.Config
+	pool       Pool
+	hooks      []bun.QueryHook
+}
+
+// Pool limits the connections kept by the handler, zero values keep the
+// database/sql defaults.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type Option func(h *Handler)
+
+// WithTLS connects to Postgres over TLS with the given configuration.
+func WithTLS(config *tls.Config) Option {
+	return func(h *Handler) {
+		h.tls = config
+	}
+}
+
+func WithPool(pool Pool) Option {
+	return func(h *Handler) {
+		h.pool = pool
+	}
+}
+
+// WithReplica sends read-only queries to the replica while it is healthy,
+// the primary serves them otherwise.
+func WithReplica(dsn string) Option {
+	return func(h *Handler) {
+		h.replicaDsn = dsn
+	}
+}
+
+func WithQueryHook(hook bun.QueryHook) Option {
+	return func(h *Handler) {
+		h.hooks = append(h.hooks, hook)
+	}
 }
 
-func NewHandler(dsn string) *Handler {
-	return &Handler{
+func NewHandler(dsn string, opts ...Option) *Handler {
+	h := &Handler{
 		dsn: dsn,
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 func (h *Handler) Database() *bun.DB {
 	h.dbOnce.Do(func() {
-		driverOptions := pgdriver.NewConnector(
-			pgdriver.WithDSN(h.dsn),
-			pgdriver.WithTLSConfig(nil),
-		)
+		var dbOptions []bun.DBOption
+		if h.replicaDsn != "" {
+			replica := h.open(h.replicaDsn)
+			dbOptions = append(dbOptions, bun.WithConnResolver(bunexp.NewReadWriteConnResolver(
+				bunexp.WithDBReplica(replica, bunexp.DBReplicaReadOnly),
+			)))
+		}
 
-		conn := sql.OpenDB(driverOptions)
-		db := bun.NewDB(conn, pgdialect.New())
+		db := bun.NewDB(h.open(h.dsn), pgdialect.New(), dbOptions...)
 
-		db.AddQueryHook(bundebug.NewQueryHook(
-			bundebug.WithEnabled(true),
-			bundebug.WithVerbose(true),
-		))
+		for _, hook := range h.hooks {
+			db.AddQueryHook(hook)
+		}
 
 		h.db = db
 	})
 
 	return h.db
 }
+
+func (h *Handler) Close() error {
+	if h.db == nil {
+		return nil
+	}
+
+	return h.db.Close()
+}
+
+func (h *Handler) Ping(ctx context.Context) error {
+	return h.Database().PingContext(ctx)
+}
+
+// Connect pings the database until it answers, backing off exponentially
+// between attempts, and gives up after timeout.
+func (h *Handler) Connect(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		err := h.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+
+		slog.WarnContext(ctx, "database is not reachable", "attempt", attempt, "retry_in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database connect: %w", err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Stats returns the statistics of the primary connection pool.
+func (h *Handler) Stats() sql.DBStats {
+	return h.Database().Stats()
+}
+
+func (h *Handler) open(dsn string) *sql.DB {
+	driverOptions := []pgdriver.Option{
+		pgdriver.WithDSN(dsn),
+	}
+
+	// Without TLS configured, only negotiate it when the DSN asks for it.
+	if h.tls != nil {
+		driverOptions = append(driverOptions, pgdriver.WithTLSConfig(h.tls))
+	} else if !hasSSLMode(dsn) {
+		driverOptions = append(driverOptions, pgdriver.WithTLSConfig(nil))
+	}
+
+	conn := sql.OpenDB(pgdriver.NewConnector(driverOptions...))
+	conn.SetMaxOpenConns(h.pool.MaxOpenConns)
+	if h.pool.MaxIdleConns != 0 {
+		conn.SetMaxIdleConns(h.pool.MaxIdleConns)
+	}
+	conn.SetConnMaxLifetime(h.pool.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(h.pool.ConnMaxIdleTime)
+
+	return conn
+}
+
+func hasSSLMode(dsn string) bool {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return false
+	}
+
+	return u.Query().Has("sslmode")
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"time"
+)
+
+const maxTxAttempts = 3
+
+// RunInTx runs fn in a transaction committed when fn returns nil and rolled
+// back otherwise. Transactions aborted by a deadlock are retried, and so are
+// serialization failures, which only happen when opts asks for the
+// repeatable read or serializable isolation: with nil opts, fn runs under
+// read committed and must lock the rows it reads before updating them. When
+// db already is a transaction, fn joins it and opts is ignored.
+func RunInTx(ctx context.Context, db bun.IDB, opts *sql.TxOptions, fn func(tx bun.IDB) error) error {
+	switch db.(type) {
+	case bun.Tx, *bun.Tx:
+		return fn(db)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = db.RunInTx(ctx, opts, func(ctx context.Context, tx bun.Tx) error {
+			return fn(tx)
+		})
+
+		if !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+		}
+	}
+
+	return err
+}
+
+func retryable(err error) bool {
+	var pgErr pgdriver.Error
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	switch pgErr.Field('C') {
+	case "40001", "40P01":
+		return true
+	}
+
+	return false
+}
+
+// ForUpdate locks the selected rows until the end of the transaction on
+// dialects that support it, use it with SelectQuery.Apply.
+func ForUpdate(q *bun.SelectQuery) *bun.SelectQuery {
+	if q.DB().Dialect().Name() != dialect.PG {
+		return q
+	}
+
+	return q.For("UPDATE")
+}
+
+// Primary returns the connection of db that reads from the primary, for
+// reads that must not lag behind the writes when a replica is configured.
+// Use it with SelectQuery.Conn.
+func Primary(db bun.IDB) bun.IConn {
+	switch db := db.(type) {
+	case *bun.DB:
+		return db.DB
+	case bun.IConn:
+		return db
+	}
+
+	return db.NewSelect().DB().DB
+}
@@ -0,0 +1,226 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+	etcdresolver "go.etcd.io/etcd/client/v3/naming/resolver"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/resolver"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	Scheme = "etcd"
+
+	// DefaultTTL is the lifetime, in seconds, of an instance registration
+	// once its process stops renewing the lease.
+	DefaultTTL = 10
+
+	prefix = "services/"
+
+	minBackoff = 250 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// Target returns the address used to dial every instance of a service,
+// e.g. etcd:///user.
+func Target(name string) string {
+	return Scheme + ":///" + name
+}
+
+// Registration is the leased etcd entry advertising a service instance.
+type Registration struct {
+	lease   clientv3.Lease
+	manager endpoints.Manager
+	key     string
+	addr    string
+	ttl     int64
+
+	mu      sync.Mutex
+	leaseID clientv3.LeaseID
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Register advertises addr as an instance of the named service. The entry is
+// bound to a lease kept alive in the background, so it disappears when the
+// instance dies without deregistering. When the lease is lost, for example
+// after etcd was unreachable for longer than ttl, a new one is granted and
+// the entry is added again.
+func Register(ctx context.Context, client *clientv3.Client, name string, addr string, ttl int64) (*Registration, error) {
+	manager, err := endpoints.NewManager(client, prefix+name)
+	if err != nil {
+		return nil, err
+	}
+
+	return register(ctx, client, manager, name, addr, ttl)
+}
+
+func register(ctx context.Context, lease clientv3.Lease, manager endpoints.Manager, name string, addr string, ttl int64) (*Registration, error) {
+	keepAliveCtx, cancel := context.WithCancel(context.Background())
+	r := &Registration{
+		lease:   lease,
+		manager: manager,
+		key:     prefix + name + "/" + addr,
+		addr:    addr,
+		ttl:     ttl,
+		ctx:     keepAliveCtx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	ch, err := r.grant(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go r.keepAlive(ch)
+
+	return r, nil
+}
+
+// grant adds the entry under a new lease and starts renewing it.
+func (r *Registration) grant(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	lease, err := r.lease.Grant(ctx, r.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.manager.AddEndpoint(ctx, r.key, endpoints.Endpoint{Addr: r.addr}, clientv3.WithLease(lease.ID)); err != nil {
+		return nil, err
+	}
+
+	ch, err := r.lease.KeepAlive(r.ctx, lease.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.leaseID = lease.ID
+	r.mu.Unlock()
+
+	return ch, nil
+}
+
+// keepAlive drains the renewals of the lease and registers the instance
+// again, with backoff, whenever the lease is lost.
+func (r *Registration) keepAlive(ch <-chan *clientv3.LeaseKeepAliveResponse) {
+	defer close(r.done)
+
+	for {
+		for range ch {
+		}
+
+		if r.ctx.Err() != nil {
+			return
+		}
+		slog.Warn("discovery lease lost, registering again", "key", r.key)
+
+		backoff := minBackoff
+		for {
+			var err error
+			if ch, err = r.grant(r.ctx); err == nil {
+				break
+			}
+			if r.ctx.Err() != nil {
+				return
+			}
+
+			slog.Warn("discovery registration failed", "key", r.key, "retry_in", backoff, "error", err)
+
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// Deregister removes the instance so that clients stop routing calls to it.
+func (r *Registration) Deregister(ctx context.Context) error {
+	r.cancel()
+	<-r.done
+
+	if err := r.manager.DeleteEndpoint(ctx, r.key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	leaseID := r.leaseID
+	r.mu.Unlock()
+
+	_, err := r.lease.Revoke(ctx, leaseID)
+	return err
+}
+
+type builder struct {
+	resolver.Builder
+}
+
+// NewBuilder returns a gRPC resolver for etcd:///<service> targets. Clients
+// receive every registered instance and are updated as instances come and go.
+func NewBuilder(client *clientv3.Client) (resolver.Builder, error) {
+	b, err := etcdresolver.NewBuilder(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &builder{Builder: b}, nil
+}
+
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	name := target.Endpoint()
+	if name == "" {
+		return nil, fmt.Errorf("discovery: missing service name in %s", target.URL.String())
+	}
+
+	target.URL.Path = "/" + prefix + name
+	target.URL.Opaque = ""
+
+	return b.Builder.Build(target, cc, opts)
+}
+
+// Dial creates a client connection balancing calls across every registered
+// instance of the named service. Instances reporting NOT_SERVING through the
+// health service are skipped. Without a registry, as with --kvs=memory, the
+// service is dialed at its static address instead.
+func Dial(client *clientv3.Client, name string, static string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target := Target(name)
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithDefaultServiceConfig(`{
+			"loadBalancingConfig": [{"round_robin": {}}],
+			"healthCheckConfig": {"serviceName": ""}
+		}`),
+	}
+
+	switch {
+	case client != nil:
+		b, err := NewBuilder(client)
+		if err != nil {
+			return nil, err
+		}
+		dialOpts = append(dialOpts, grpc.WithResolvers(b))
+	case static != "":
+		slog.Warn("service registry disabled, dialing the static address", "name", name, "addr", static)
+		target = "passthrough:///" + static
+	default:
+		return nil, fmt.Errorf("cannot resolve %s without an etcd registry or a static address in services.%s", name, name)
+	}
+
+	return grpc.NewClient(target, append(dialOpts, opts...)...)
+}
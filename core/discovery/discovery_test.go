@@ -0,0 +1,199 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDialStatic(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() {
+		_ = srv.Serve(listen)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := Dial(nil, "user", listen.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("got status %s, want SERVING", res.GetStatus())
+	}
+}
+
+func TestDialWithoutAddress(t *testing.T) {
+	if _, err := Dial(nil, "user", ""); err == nil {
+		t.Fatal("dialed a service without registry nor static address")
+	}
+}
+
+// registry stands in for etcd: endpoints are bound to the last lease granted
+// and dropped with it.
+type registry struct {
+	clientv3.Lease
+	endpoints.Manager
+
+	mu        sync.Mutex
+	lastID    clientv3.LeaseID
+	failures  int
+	renewals  map[clientv3.LeaseID]chan *clientv3.LeaseKeepAliveResponse
+	endpoints map[string]clientv3.LeaseID
+	revoked   []clientv3.LeaseID
+}
+
+func newRegistry() *registry {
+	return &registry{
+		renewals:  make(map[clientv3.LeaseID]chan *clientv3.LeaseKeepAliveResponse),
+		endpoints: make(map[string]clientv3.LeaseID),
+	}
+}
+
+func (r *registry) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		return nil, errors.New("etcd unavailable")
+	}
+
+	r.lastID++
+	r.renewals[r.lastID] = make(chan *clientv3.LeaseKeepAliveResponse)
+	return &clientv3.LeaseGrantResponse{ID: r.lastID, TTL: ttl}, nil
+}
+
+func (r *registry) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ch := r.renewals[id]
+	go func() {
+		<-ctx.Done()
+		r.expire(id)
+	}()
+
+	return ch, nil
+}
+
+func (r *registry) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	r.expire(id)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revoked = append(r.revoked, id)
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+// expire drops the lease and the endpoints bound to it, as etcd does once
+// it is revoked or not renewed in time.
+func (r *registry) expire(id clientv3.LeaseID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ch, ok := r.renewals[id]; ok {
+		close(ch)
+		delete(r.renewals, id)
+	}
+
+	for key, lease := range r.endpoints {
+		if lease == id {
+			delete(r.endpoints, key)
+		}
+	}
+}
+
+func (r *registry) AddEndpoint(ctx context.Context, key string, endpoint endpoints.Endpoint, opts ...clientv3.OpOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.endpoints[key] = r.lastID
+	return nil
+}
+
+func (r *registry) DeleteEndpoint(ctx context.Context, key string, opts ...clientv3.OpOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.endpoints, key)
+	return nil
+}
+
+func (r *registry) lease(key string) (clientv3.LeaseID, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.endpoints[key]
+	return id, ok
+}
+
+func TestRegisterAfterLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry()
+
+	r, err := register(ctx, reg, reg, "user", "10.0.0.1:50051", DefaultTTL)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	first, ok := reg.lease(r.key)
+	if !ok {
+		t.Fatal("instance not registered")
+	}
+
+	// The next grant fails, as while etcd is still unreachable.
+	reg.mu.Lock()
+	reg.failures = 1
+	reg.mu.Unlock()
+
+	if _, err := reg.Revoke(ctx, first); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if id, ok := reg.lease(r.key); ok && id != first {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("instance not registered again after its lease was revoked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := r.Deregister(ctx); err != nil {
+		t.Fatalf("deregister: %v", err)
+	}
+	if _, ok := reg.lease(r.key); ok {
+		t.Fatal("instance still registered")
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if len(reg.renewals) != 0 {
+		t.Fatalf("%d leases left, want every lease revoked", len(reg.renewals))
+	}
+}
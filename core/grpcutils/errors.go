@@ -0,0 +1,230 @@
+package grpcutils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// SQLSTATE codes of the integrity violations mapped by From.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgInvalidText         = "22P02"
+)
+
+// Extended result codes of the SQLite constraint violations, as reported by
+// the driver of the test harness.
+const (
+	sqliteCheck      = 275
+	sqliteForeignKey = 787
+	sqliteNotNull    = 1299
+	sqlitePrimaryKey = 1555
+	sqliteUnique     = 2067
+)
+
+// sqliteError is implemented by the errors of the modernc.org/sqlite driver,
+// matched without linking the driver into the services.
+type sqliteError interface {
+	error
+	Code() int
+}
+
+func NotFound(msg string, args ...interface{}) error {
+	return status.Errorf(codes.NotFound, msg, args...)
+}
+
+func AlreadyExists(msg string, args ...interface{}) error {
+	return status.Errorf(codes.AlreadyExists, msg, args...)
+}
+
+func FailedPrecondition(msg string, args ...interface{}) error {
+	return status.Errorf(codes.FailedPrecondition, msg, args...)
+}
+
+func Unauthenticated(msg string, args ...interface{}) error {
+	return status.Errorf(codes.Unauthenticated, msg, args...)
+}
+
+func PermissionDenied(msg string, args ...interface{}) error {
+	return status.Errorf(codes.PermissionDenied, msg, args...)
+}
+
+// FieldViolation describes why the value of a request field was rejected.
+func FieldViolation(field string, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+// InvalidArgument returns an InvalidArgument status carrying the field
+// violations as google.rpc.BadRequest details.
+func InvalidArgument(msg string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	return withViolations(codes.InvalidArgument, msg, violations...)
+}
+
+// From translates err into a gRPC status error. Errors that already are
+// statuses are returned unchanged, database errors are mapped to the closest
+// code and anything else becomes Internal without leaking its message.
+func From(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return NotFound("not found")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		if st := fromPostgres(pgErr); st != nil {
+			return st
+		}
+	}
+
+	var sqliteErr sqliteError
+	if errors.As(err, &sqliteErr) {
+		if st := fromSQLite(sqliteErr); st != nil {
+			return st
+		}
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+// Violations returns the field violations attached to a status error.
+func Violations(err error) map[string]string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	violations := make(map[string]string)
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				violations[violation.Field] = violation.Description
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
+
+func fromPostgres(err pgdriver.Error) error {
+	column := err.Field('c')
+	if column == "" {
+		column = keyColumn(err.Field('D'))
+	}
+
+	return fromSQLState(err.Field('C'), column)
+}
+
+func fromSQLite(err sqliteError) error {
+	column := constraintColumn(err.Error())
+
+	switch err.Code() {
+	case sqliteUnique, sqlitePrimaryKey:
+		return fromSQLState(pgUniqueViolation, column)
+	case sqliteForeignKey:
+		return fromSQLState(pgForeignKeyViolation, column)
+	case sqliteNotNull:
+		return fromSQLState(pgNotNullViolation, column)
+	case sqliteCheck:
+		return fromSQLState(pgCheckViolation, column)
+	}
+
+	return nil
+}
+
+// fromSQLState maps the SQLSTATE of an integrity violation on column, empty
+// when unknown, to a status.
+func fromSQLState(state string, column string) error {
+	switch state {
+	case pgUniqueViolation:
+		if column == "" {
+			return AlreadyExists("already exists")
+		}
+		return withViolations(codes.AlreadyExists, fmt.Sprintf("%s already exists", column), FieldViolation(column, "already exists"))
+	case pgForeignKeyViolation:
+		if column == "" {
+			return FailedPrecondition("referenced resource does not exist or is still referenced")
+		}
+		return withViolations(codes.FailedPrecondition, fmt.Sprintf("%s references a missing or used resource", column), FieldViolation(column, "references a missing or used resource"))
+	case pgNotNullViolation:
+		return InvalidArgument(fmt.Sprintf("%s is required", column), FieldViolation(column, "is required"))
+	case pgCheckViolation, pgInvalidText:
+		return InvalidArgument("invalid value")
+	}
+
+	return nil
+}
+
+// keyColumn extracts the column from a detail message such as
+// "Key (email)=(...) already exists.", without the offending value.
+func keyColumn(detail string) string {
+	_, rest, ok := strings.Cut(detail, "Key (")
+	if !ok {
+		return ""
+	}
+
+	column, _, ok := strings.Cut(rest, ")")
+	if !ok {
+		return ""
+	}
+
+	return column
+}
+
+// constraintColumn extracts the column from a SQLite message such as
+// "UNIQUE constraint failed: users.email (2067)".
+func constraintColumn(msg string) string {
+	_, columns, ok := strings.Cut(msg, " constraint failed: ")
+	if !ok {
+		return ""
+	}
+
+	columns, _, _ = strings.Cut(columns, " (")
+	column, _, _ := strings.Cut(columns, ",")
+	if _, name, ok := strings.Cut(column, "."); ok {
+		return name
+	}
+
+	return column
+}
+
+func withViolations(code codes.Code, msg string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(code, msg)
+	if len(violations) == 0 {
+		return st.Err()
+	}
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
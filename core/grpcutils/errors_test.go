@@ -0,0 +1,85 @@
+package grpcutils
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"reflect"
+	"testing"
+)
+
+type fakeSQLiteError struct {
+	msg  string
+	code int
+}
+
+func (e *fakeSQLiteError) Error() string { return e.msg }
+func (e *fakeSQLiteError) Code() int     { return e.code }
+
+func TestFrom(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		err        error
+		code       codes.Code
+		violations map[string]string
+	}{
+		{"status", NotFound("user 1 not found"), codes.NotFound, nil},
+		{"no rows", fmt.Errorf("scan: %w", sql.ErrNoRows), codes.NotFound, nil},
+		{"canceled", context.Canceled, codes.Canceled, nil},
+		{"deadline", context.DeadlineExceeded, codes.DeadlineExceeded, nil},
+		{"unknown", errors.New("connection reset"), codes.Internal, nil},
+		{
+			"sqlite unique",
+			&fakeSQLiteError{"constraint failed: UNIQUE constraint failed: users.email (2067)", sqliteUnique},
+			codes.AlreadyExists,
+			map[string]string{"email": "already exists"},
+		},
+		{
+			"sqlite foreign key",
+			&fakeSQLiteError{"constraint failed: FOREIGN KEY constraint failed (787)", sqliteForeignKey},
+			codes.FailedPrecondition,
+			nil,
+		},
+		{
+			"sqlite not null",
+			&fakeSQLiteError{"constraint failed: NOT NULL constraint failed: refresh_tokens.hash (1299)", sqliteNotNull},
+			codes.InvalidArgument,
+			map[string]string{"hash": "is required"},
+		},
+		{"sqlite busy", &fakeSQLiteError{"database is locked (5)", 5}, codes.Internal, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := From(tt.err)
+
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("got code %s, want %s", got, tt.code)
+			}
+			if got := Violations(err); !reflect.DeepEqual(got, tt.violations) {
+				t.Fatalf("got violations %v, want %v", got, tt.violations)
+			}
+		})
+	}
+}
+
+func TestFromHidesInternalErrors(t *testing.T) {
+	err := From(errors.New("password authentication failed for user admin"))
+
+	if msg := status.Convert(err).Message(); msg != "internal error" {
+		t.Fatalf("leaked %q", msg)
+	}
+}
+
+func TestKeyColumn(t *testing.T) {
+	for detail, want := range map[string]string{
+		"Key (email)=(jane@example.org) already exists.":       "email",
+		"Key (role_id)=(9) is not present in table \"roles\".": "role_id",
+		"no key here": "",
+	} {
+		if got := keyColumn(detail); got != want {
+			t.Errorf("keyColumn(%q) = %q, want %q", detail, got, want)
+		}
+	}
+}
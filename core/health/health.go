@@ -0,0 +1,195 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTimeout  = 2 * time.Second
+	DefaultInterval = 5 * time.Second
+)
+
+// Check reports whether a dependency is usable, a nil error means healthy.
+type Check func(ctx context.Context) error
+
+// Checker runs a set of named dependency checks. Failing checks make the
+// service unavailable, unless they were added as optional.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	optional map[string]bool
+	timeout  time.Duration
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks:   make(map[string]Check),
+		optional: make(map[string]bool),
+		timeout:  DefaultTimeout,
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+	delete(c.optional, name)
+}
+
+// AddOptional adds a check whose failure is reported without making the
+// service unavailable, for dependencies that serving calls do not need, such
+// as the store the configuration was already loaded from.
+func (c *Checker) AddOptional(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+	c.optional[name] = true
+}
+
+// Critical reports whether the failure of the named check makes the service
+// unavailable.
+func (c *Checker) Critical(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return !c.optional[name]
+}
+
+// Run executes every check concurrently and returns the failures by name.
+func (c *Checker) Run(ctx context.Context) map[string]error {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = make(map[string]error)
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			if err := check(ctx); err != nil {
+				mu.Lock()
+				failures[name] = err
+				mu.Unlock()
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return failures
+}
+
+func (c *Checker) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Server exposes the checker through the grpc.health.v1 service. The overall
+// status is refreshed periodically, is NOT_SERVING while a critical check
+// fails, and flips to NOT_SERVING on shutdown.
+type Server struct {
+	*health.Server
+
+	checker  *Checker
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+func Register(srv *grpc.Server, checker *Checker) *Server {
+	s := &Server{
+		Server:   health.NewServer(),
+		checker:  checker,
+		interval: DefaultInterval,
+	}
+	s.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, s)
+
+	return s
+}
+
+func (s *Server) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.update(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.Shutdown()
+}
+
+func (s *Server) update(ctx context.Context) {
+	status := healthpb.HealthCheckResponse_SERVING
+	for name, err := range s.checker.Run(ctx) {
+		slog.WarnContext(ctx, "health check failed", "check", name, "error", err)
+		if s.checker.Critical(name) {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+
+	if ctx.Err() == nil {
+		s.SetServingStatus("", status)
+	}
+}
+
+// GRPC checks a downstream service through its grpc.health.v1 endpoint.
+func GRPC(conn grpc.ClientConnInterface) Check {
+	client := healthpb.NewHealthClient(conn)
+
+	return func(ctx context.Context) error {
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+
+		if res.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("status %s", res.Status)
+		}
+		return nil
+	}
+}
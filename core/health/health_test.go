@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"testing"
+)
+
+func TestServerIgnoresOptionalChecks(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("postgres", func(ctx context.Context) error {
+		return nil
+	})
+	checker.AddOptional("kvs", func(ctx context.Context) error {
+		return errors.New("etcd unreachable")
+	})
+
+	s := &Server{Server: health.NewServer(), checker: checker}
+	ctx := context.Background()
+
+	s.update(ctx)
+	if got := status(t, s); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("optional failure: got %s, want SERVING", got)
+	}
+
+	checker.Add("kvs", func(ctx context.Context) error {
+		return errors.New("etcd unreachable")
+	})
+
+	s.update(ctx)
+	if got := status(t, s); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("critical failure: got %s, want NOT_SERVING", got)
+	}
+}
+
+func status(t *testing.T, s *Server) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	res, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	return res.GetStatus()
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"github.com/uptrace/bunrouter"
+	"net/http"
+)
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Register adds the liveness and readiness endpoints to the router. /healthz
+// only reports that the process answers, /readyz runs every check and is
+// unavailable while a critical one fails, degraded while an optional one does.
+func (c *Checker) Register(r *bunrouter.Router) {
+	r.GET("/healthz", func(w http.ResponseWriter, req bunrouter.Request) error {
+		return bunrouter.JSON(w, report{Status: "ok"})
+	})
+
+	r.GET("/readyz", func(w http.ResponseWriter, req bunrouter.Request) error {
+		failures := c.Run(req.Context())
+
+		res := report{
+			Status: "ok",
+			Checks: make(map[string]string),
+		}
+		unavailable := false
+		for _, name := range c.Names() {
+			res.Checks[name] = "ok"
+			if err, ok := failures[name]; ok {
+				res.Checks[name] = err.Error()
+				if c.Critical(name) {
+					unavailable = true
+				}
+			}
+		}
+
+		switch {
+		case unavailable:
+			res.Status = "unavailable"
+		case len(failures) > 0:
+			res.Status = "degraded"
+		}
+
+		if unavailable {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		return bunrouter.JSON(w, res)
+	})
+}
@@ -0,0 +1,46 @@
+package httputils
+
+import (
+	"context"
+	"github.com/alpha-omega-corp/cloud/core/server"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strings"
+)
+
+var ErrMissingToken = Unauthorized("missing bearer token")
+
+// Verifier checks token and returns ctx with the authenticated caller.
+type Verifier func(ctx context.Context, token string) (context.Context, error)
+
+// BearerToken returns the token of the Authorization header of req.
+func BearerToken(req *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+
+	return token, ok && token != ""
+}
+
+// Authenticate answers 401 to the requests without a valid bearer token.
+// The token of the others is forwarded to the gRPC services they call.
+func Authenticate(verify Verifier) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			token, ok := BearerToken(req.Request)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				return ErrMissingToken
+			}
+
+			ctx, err := verify(server.WithAuthToken(req.Context(), token), token)
+			if err != nil {
+				if From(err, false).Status == http.StatusUnauthorized {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+				return err
+			}
+
+			return next(w, req.WithContext(ctx))
+		}
+	}
+}
@@ -3,9 +3,16 @@ package httputils
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 var (
@@ -18,6 +25,10 @@ func NotFound(msg string, args ...interface{}) Error {
 	return NewError(http.StatusNotFound, "not_found", msg, args...)
 }
 
+func Unauthorized(msg string, args ...interface{}) Error {
+	return NewError(http.StatusUnauthorized, "unauthorized", msg, args...)
+}
+
 func Forbidden(msg string, args ...interface{}) Error {
 	return NewError(http.StatusForbidden, "forbidden", msg, args...)
 }
@@ -29,9 +40,10 @@ func BadRequest(code, msg string, args ...interface{}) Error {
 //------------------------------------------------------------------------------
 
 type Error struct {
-	Status  int    `json:"status"`
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Status  int               `json:"status"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
 }
 
 func NewError(status int, code, msg string, args ...interface{}) Error {
@@ -52,18 +64,35 @@ func (e Error) Error() string {
 //------------------------------------------------------------------------------
 
 func From(err error, debug bool) Error {
-	switch err {
-	case io.EOF:
+	switch {
+	case errors.Is(err, io.EOF):
 		return errEOF
-	case sql.ErrNoRows:
+	case errors.Is(err, sql.ErrNoRows):
 		return ErrNotFound
 	}
 
-	switch err := err.(type) {
-	case Error:
-		return err
-	case *json.SyntaxError:
-		return BadRequest("json_syntax", err.Error())
+	var httpErr Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return BadRequest("json_syntax", syntaxErr.Error())
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return BadRequest("json_type", "invalid value for field %s", typeErr.Field)
+	}
+
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return BadRequest("invalid_parameter", "invalid number %q", numErr.Num)
+	}
+
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		return fromStatus(st, debug)
 	}
 
 	if debug {
@@ -71,3 +100,78 @@ func From(err error, debug bool) Error {
 	}
 	return ErrInternal
 }
+
+// FromStatus converts the status and error fields embedded in a gRPC response
+// into an Error, it returns nil for successful statuses.
+func FromStatus(code int64, msg string) error {
+	if code < http.StatusBadRequest {
+		return nil
+	}
+
+	if msg == "" {
+		msg = http.StatusText(int(code))
+	}
+
+	return NewError(int(code), snake(http.StatusText(int(code))), msg)
+}
+
+func fromStatus(st *status.Status, debug bool) Error {
+	code := HTTPStatus(st.Code())
+	if code >= http.StatusInternalServerError && !debug {
+		return NewError(code, snake(st.Code().String()), http.StatusText(code))
+	}
+
+	httpErr := NewError(code, snake(st.Code().String()), st.Message())
+	httpErr.Fields = grpcutils.Violations(st.Err())
+
+	return httpErr
+}
+
+// HTTPStatus returns the HTTP status matching a gRPC status code.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+func snake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			if i > 0 && s[i-1] != ' ' {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package httputils
+
+import (
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	"github.com/alpha-omega-corp/cloud/core/server"
+	"github.com/uptrace/bunrouter"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// RequestLogger assigns a request ID to every request, reusing the one sent
+// by the caller, and logs the request once it completes. The ID is returned
+// in the X-Request-Id header and forwarded to the gRPC services.
+func RequestLogger(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		start := time.Now()
+
+		id := req.Header.Get(server.RequestIDHeader)
+		if id == "" {
+			id = server.NewRequestID()
+		}
+		w.Header().Set(server.RequestIDHeader, id)
+
+		ctx := server.WithRequestID(req.Context(), id)
+		ctx = logging.With(ctx, logging.KeyRequestID, id)
+
+		rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		err := next(rw, req.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rw.status >= http.StatusInternalServerError || err != nil {
+			level = slog.LevelError
+		}
+
+		args := []any{
+			"method", req.Method,
+			"route", req.Route(),
+			"path", req.URL.Path,
+			"status", rw.status,
+			"duration", time.Since(start),
+		}
+		if err != nil {
+			args = append(args, "error", err)
+		}
+
+		slog.Log(ctx, level, "request", args...)
+		return err
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,27 +1,56 @@
 package httputils
 
 import (
+	"context"
 	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/metrics"
+	"github.com/uptrace/bunrouter"
+	"log/slog"
 	"net/http"
-	"os"
-	"runtime"
+	"runtime/debug"
 )
 
-type ExitOnPanicHandler struct {
-	Next http.Handler
+// Reporter receives the panics recovered by Recover, for example to forward
+// them to an error tracking service.
+type Reporter interface {
+	Report(ctx context.Context, err error, stack []byte)
 }
 
-func (h ExitOnPanicHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	defer func() {
-		if err := recover(); err != nil {
-			buf := make([]byte, 1<<20)
-			n := runtime.Stack(buf, true)
-			_, err := fmt.Fprintf(os.Stderr, "panic: %v\n\n%s", err, buf[:n])
-			if err != nil {
-				return
-			}
-			os.Exit(1)
+// Recover turns a panic in a handler into a 500 response. The stack is logged
+// with the request fields, counted in metrics and handed to the reporters.
+func Recover(reporters ...Reporter) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				stack := debug.Stack()
+				panicErr, ok := r.(error)
+				if !ok {
+					panicErr = fmt.Errorf("%v", r)
+				}
+
+				ctx := req.Context()
+				slog.ErrorContext(ctx, "http panic", "route", req.Route(), "panic", panicErr, "stack", string(stack))
+				metrics.Panic(req.Route())
+
+				for _, reporter := range reporters {
+					reporter.Report(ctx, panicErr, stack)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(ErrInternal.Status)
+				err = bunrouter.JSON(w, ErrInternal)
+			}()
+
+			return next(w, req)
 		}
-	}()
-	h.Next.ServeHTTP(w, req)
+	}
 }
@@ -0,0 +1,52 @@
+package httputils
+
+import (
+	"github.com/uptrace/bunrouter"
+	"log/slog"
+	"net/http"
+)
+
+// statusResponse is implemented by the gRPC responses that report their
+// outcome in status and error fields.
+type statusResponse interface {
+	GetStatus() int64
+	GetError() string
+}
+
+// Render writes res as JSON. Responses carrying a status field are written
+// with that HTTP status, or returned as an Error when it is a failure.
+func Render(w http.ResponseWriter, res any) error {
+	if res, ok := res.(statusResponse); ok {
+		if err := FromStatus(res.GetStatus(), res.GetError()); err != nil {
+			return err
+		}
+
+		if code := int(res.GetStatus()); code != 0 && code != http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+		}
+	}
+
+	return bunrouter.JSON(w, res)
+}
+
+// ErrorHandler renders the errors returned by handlers as an Error JSON body
+// with the matching HTTP status. Server errors are logged with their cause.
+func ErrorHandler(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		err := next(w, req)
+		if err == nil {
+			return nil
+		}
+
+		httpErr := From(err, false)
+		if httpErr.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(req.Context(), "request failed", "route", req.Route(), "error", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpErr.Status)
+
+		return bunrouter.JSON(w, httpErr)
+	}
+}
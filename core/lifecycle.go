@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const DefaultDrainTimeout = 15 * time.Second
+
+// Hook is a named pair of callbacks run when the application starts and stops.
+// OnStart must not block, long-running work belongs in its own goroutine.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle runs hooks in registration order on start and in reverse order on stop.
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+	timeout time.Duration
+}
+
+func NewLifecycle(timeout time.Duration) *Lifecycle {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+
+	return &Lifecycle{
+		timeout: timeout,
+	}
+}
+
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, hook)
+}
+
+func (l *Lifecycle) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		l.timeout = timeout
+	}
+}
+
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.started < len(l.hooks) {
+		hook := l.hooks[l.started]
+		if hook.OnStart != nil {
+			if err := hook.OnStart(ctx); err != nil {
+				return fmt.Errorf("start %s: %w", hook.Name, err)
+			}
+		}
+		l.started++
+	}
+
+	return nil
+}
+
+// Stop runs the OnStop callbacks of every started hook in reverse order. The
+// whole sequence shares a single drain timeout.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	var errs []error
+	for ; l.started > 0; l.started-- {
+		hook := l.hooks[l.started-1]
+		if hook.OnStop == nil {
+			continue
+		}
+
+		if err := hook.OnStop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run starts every hook, waits for a termination signal and stops them again.
+func (l *Lifecycle) Run(ctx context.Context) (os.Signal, error) {
+	if err := l.Start(ctx); err != nil {
+		return nil, errors.Join(err, l.Stop(context.Background()))
+	}
+
+	sig := l.Wait(ctx)
+
+	return sig, l.Stop(context.Background())
+}
+
+func (l *Lifecycle) Wait(ctx context.Context) os.Signal {
+	ch := make(chan os.Signal, 3)
+	signal.Notify(
+		ch,
+		syscall.SIGINT,
+		syscall.SIGQUIT,
+		syscall.SIGTERM,
+	)
+	defer signal.Stop(ch)
+
+	select {
+	case sig := <-ch:
+		return sig
+	case <-ctx.Done():
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func recordingHook(name string, calls *[]string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			*calls = append(*calls, "start "+name)
+			return startErr
+		},
+		OnStop: func(ctx context.Context) error {
+			*calls = append(*calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var calls []string
+	l := NewLifecycle(time.Second)
+	l.Append(recordingHook("db", &calls, nil))
+	l.Append(recordingHook("grpc", &calls, nil))
+
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	want := []string{"start db", "start grpc", "stop grpc", "stop db"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("got %v, want %v", calls, want)
+	}
+}
+
+func TestLifecycleStartFailure(t *testing.T) {
+	var calls []string
+	failure := errors.New("port in use")
+
+	l := NewLifecycle(time.Second)
+	l.Append(recordingHook("db", &calls, nil))
+	l.Append(recordingHook("grpc", &calls, failure))
+	l.Append(recordingHook("metrics", &calls, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := l.Run(ctx)
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want %v", err, failure)
+	}
+
+	// Only the hooks that started are stopped.
+	want := []string{"start db", "start grpc", "stop db"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("got %v, want %v", calls, want)
+	}
+}
+
+func TestLifecycleDrainTimeout(t *testing.T) {
+	l := NewLifecycle(10 * time.Millisecond)
+	l.Append(Hook{
+		Name: "slow",
+		OnStop: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	if err := l.Stop(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
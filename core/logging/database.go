@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"log/slog"
+	"time"
+)
+
+// QueryHook logs every query at debug level, and failed ones at error level.
+// Queries are logged with their placeholders, parameters are never printed.
+type QueryHook struct{}
+
+var _ bun.QueryHook = (*QueryHook)(nil)
+
+func NewQueryHook() *QueryHook {
+	return &QueryHook{}
+}
+
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	level := slog.LevelDebug
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		level = slog.LevelError
+	}
+
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	args := []any{
+		"operation", event.Operation(),
+		"query", redact(event),
+		"duration", time.Since(event.StartTime),
+	}
+
+	if event.Err != nil {
+		args = append(args, "error", event.Err)
+	}
+
+	slog.Log(ctx, level, "query", args...)
+}
+
+// redact renders the query without its arguments.
+func redact(event *bun.QueryEvent) string {
+	if event.IQuery != nil {
+		if b, err := event.IQuery.AppendQuery(schema.NewNopFormatter(), nil); err == nil {
+			return string(b)
+		}
+	}
+
+	return event.QueryTemplate
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+const password = "hunter2-hash"
+
+type account struct {
+	bun.BaseModel `bun:"table:accounts"`
+
+	Id       int64  `bun:",pk,autoincrement"`
+	Email    string `bun:"email"`
+	Password string `bun:"password"`
+}
+
+// newDB opens an in-memory database logging its queries into the returned
+// buffer.
+func newDB(t *testing.T) (*bun.DB, *bytes.Buffer) {
+	t.Helper()
+
+	conn, err := sql.Open(sqliteshim.ShimName, ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	conn.SetMaxOpenConns(1)
+
+	db := bun.NewDB(conn, sqlitedialect.New())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	if _, err := db.NewCreateTable().Model((*account)(nil)).Exec(context.Background()); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+	})
+
+	db.AddQueryHook(NewQueryHook())
+
+	return db, buf
+}
+
+func TestQueryHookRedactsValues(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		query func(db *bun.DB) error
+	}{
+		{
+			name: "insert",
+			query: func(db *bun.DB) error {
+				_, err := db.NewInsert().Model(&account{Email: "jane@example.org", Password: password}).Exec(ctx)
+				return err
+			},
+		},
+		{
+			name: "update",
+			query: func(db *bun.DB) error {
+				_, err := db.NewUpdate().
+					Model((*account)(nil)).
+					Set("password = ?", password).
+					Where("email = ?", "jane@example.org").
+					Exec(ctx)
+				return err
+			},
+		},
+		{
+			name: "raw query template",
+			query: func(db *bun.DB) error {
+				_, err := db.ExecContext(ctx, "UPDATE accounts SET password = ? WHERE email = ?", password, "jane@example.org")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, buf := newDB(t)
+
+			if err := tt.query(db); err != nil {
+				t.Fatalf("query: %v", err)
+			}
+
+			logged := buf.String()
+			if !strings.Contains(logged, "accounts") {
+				t.Fatalf("query was not logged: %q", logged)
+			}
+			if strings.Contains(logged, password) || strings.Contains(logged, "jane@example.org") {
+				t.Fatalf("logged the query values: %q", logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	KeyService   = "service"
+	KeyRequestID = "request_id"
+	KeyUserID    = "user_id"
+	KeyRPC       = "rpc"
+
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type contextKey struct{}
+
+// Setup installs the default slog logger of the named service, writing to
+// stderr with the configured level and format. Every record carries the
+// service name and the fields attached to its context with With.
+func Setup(name string, c *types.Logging) (*slog.Logger, error) {
+	return New(os.Stderr, name, c)
+}
+
+func New(w io.Writer, name string, c *types.Logging) (*slog.Logger, error) {
+	if c == nil {
+		c = &types.Logging{}
+	}
+
+	var level slog.Level
+	if c.Level != "" {
+		if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+			return nil, fmt.Errorf("logging level: %w", err)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(c.Format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown logging format %q", c.Format)
+	}
+
+	logger := slog.New(&contextHandler{Handler: handler}).With(KeyService, name)
+	slog.SetDefault(logger)
+
+	return logger, nil
+}
+
+// With returns a copy of ctx carrying additional log fields, given as
+// alternating keys and values like slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.Record{}
+	record.Add(args...)
+
+	attrs := append([]slog.Attr{}, fields(ctx)...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// Fatal logs msg at error level and exits the process.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func fields(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(fields(ctx)...)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
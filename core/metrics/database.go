@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+var (
+	dbQueries  = Counter("db_queries_total", "Database queries by operation and result.", "operation", "result")
+	dbDuration = Histogram("db_query_duration_seconds", "Database query latencies by operation.", "operation")
+)
+
+// QueryHook times every query run through bun.
+type QueryHook struct{}
+
+var _ bun.QueryHook = (*QueryHook)(nil)
+
+func NewQueryHook() *QueryHook {
+	return &QueryHook{}
+}
+
+func (h *QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	operation := event.Operation()
+
+	result := "ok"
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		result = "error"
+	}
+
+	dbQueries.WithLabelValues(operation, result).Inc()
+	dbDuration.WithLabelValues(operation).Observe(time.Since(event.StartTime).Seconds())
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
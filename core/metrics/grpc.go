@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"google.golang.org/grpc/codes"
+	"time"
+)
+
+var (
+	grpcHandled  = Counter("grpc_server_handled_total", "gRPC calls completed by method and status code.", "method", "code")
+	grpcDuration = Histogram("grpc_server_handling_seconds", "gRPC call latencies by method.", "method")
+)
+
+// GRPC records per-method call counts and latencies, see server.WithMetrics.
+type GRPC struct{}
+
+func NewGRPC() *GRPC {
+	return &GRPC{}
+}
+
+func (g *GRPC) Observe(method string, code codes.Code, elapsed time.Duration) {
+	grpcHandled.WithLabelValues(method, code.String()).Inc()
+	grpcDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = Counter("http_requests_total", "HTTP requests by route, method and status code.", "route", "method", "code")
+	httpDuration = Histogram("http_request_duration_seconds", "HTTP request latencies by route and method.", "route", "method")
+	httpPanics   = Counter("http_panics_total", "Panics recovered while handling HTTP requests, by route.", "route")
+)
+
+// Panic counts a panic recovered while serving the route.
+func Panic(route string) {
+	httpPanics.WithLabelValues(route).Inc()
+}
+
+// Middleware records the rate, status code and latency of the requests
+// handled by the router, labelled by route pattern.
+func Middleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, code: http.StatusOK}
+
+		err := next(rw, req)
+
+		route := req.Route()
+		if route == "" {
+			route = "unknown"
+		}
+
+		httpRequests.WithLabelValues(route, req.Method, strconv.Itoa(rw.code)).Inc()
+		httpDuration.WithLabelValues(route, req.Method).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
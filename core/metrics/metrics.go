@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+)
+
+const Path = "/metrics"
+
+// Handler serves the metrics of the default Prometheus registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Register exposes the metrics endpoint on the router.
+func Register(r *bunrouter.Router) {
+	r.GET(Path, bunrouter.HTTPHandler(Handler()))
+}
+
+// NewServer returns an HTTP server exposing only the metrics endpoint, for
+// applications that have no router of their own.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+}
+
+// Counter registers a counter vector in the default registry.
+func Counter(name string, help string, labels ...string) *prometheus.CounterVec {
+	return promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, labels)
+}
+
+// Histogram registers a histogram vector with the default buckets.
+func Histogram(name string, help string, labels ...string) *prometheus.HistogramVec {
+	return promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: prometheus.DefBuckets,
+	}, labels)
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dbfixture"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/migrate"
+	"github.com/urfave/cli/v3"
+	"os"
+	"strings"
+)
+
+const fixturesPath = "cmd/fixtures"
+
+// WithMigrations sets the versioned migrations applied by the db commands.
+// Services usually embed them from their cmd/migrations package.
+func (app *App) WithMigrations(migrations *migrate.Migrations) *App {
+	app.migrations = migrations
+	return app
+}
+
+func (app *App) dbCommand() *cli.Command {
+	// Creating the files only needs the migrations directory, the database
+	// is never reached.
+	createCmd := &cli.Command{
+		Name:      "create",
+		Usage:     "create up and down SQL migrations",
+		Category:  "migrations",
+		ArgsUsage: "<name>",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			name := strings.Join(cmd.Args().Slice(), "_")
+			if name == "" {
+				return errors.New("migration name is required")
+			}
+
+			files, err := app.migrator(bun.NewDB(nil, pgdialect.New())).CreateTxSQLMigrations(ctx, name)
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
+				fmt.Printf("created migration %s (%s)\n", file.Name, file.Path)
+			}
+
+			return nil
+		},
+	}
+
+	seedCmd := app.createDbCommand("fixtures", "seed", "load fixtures into the database", func(ctx context.Context, cmd *cli.Command) error {
+		var opts []dbfixture.FixtureOption
+		if cmd.Bool("truncate") {
+			opts = append(opts, dbfixture.WithTruncateTables())
+		}
+
+		fixture := dbfixture.New(app.dbHandler.Database(), opts...)
+		if err := fixture.Load(ctx, os.DirFS(fixturesPath), "fixture.yml"); err != nil {
+			return fmt.Errorf("load fixtures: %w", err)
+		}
+
+		fmt.Printf("fixtures loaded\n")
+		return nil
+	})
+	seedCmd.Flags = append(seedCmd.Flags, &cli.BoolFlag{
+		Name:  "truncate",
+		Usage: "truncate tables before loading fixtures",
+	})
+
+	return &cli.Command{
+		Name:  "db",
+		Usage: "manage database migrations",
+		Commands: []*cli.Command{
+			app.createDbCommand("migrations", "init", "create the migration tables", func(ctx context.Context, cmd *cli.Command) error {
+				if err := app.migrator(app.dbHandler.Database()).Init(ctx); err != nil {
+					return err
+				}
+
+				fmt.Printf("migration tables created\n")
+				return nil
+			}),
+			app.createDbCommand("migrations", "migrate", "apply pending migrations", func(ctx context.Context, cmd *cli.Command) error {
+				migrator := app.migrator(app.dbHandler.Database())
+				if err := migrator.Lock(ctx); err != nil {
+					return err
+				}
+				defer migrator.Unlock(ctx)
+
+				group, err := migrator.Migrate(ctx)
+				if err != nil {
+					return err
+				}
+
+				if group.IsZero() {
+					fmt.Printf("there are no new migrations to run (database is up to date)\n")
+					return nil
+				}
+				fmt.Printf("migrated to %s\n", group)
+				return nil
+			}),
+			app.createDbCommand("migrations", "rollback", "roll back the last migration group", func(ctx context.Context, cmd *cli.Command) error {
+				migrator := app.migrator(app.dbHandler.Database())
+				if err := migrator.Lock(ctx); err != nil {
+					return err
+				}
+				defer migrator.Unlock(ctx)
+
+				group, err := migrator.Rollback(ctx)
+				if err != nil {
+					return err
+				}
+
+				if group.IsZero() {
+					fmt.Printf("there are no groups to roll back\n")
+					return nil
+				}
+				fmt.Printf("rolled back %s\n", group)
+				return nil
+			}),
+			app.createDbCommand("migrations", "status", "print migrations status", func(ctx context.Context, cmd *cli.Command) error {
+				ms, err := app.migrator(app.dbHandler.Database()).MigrationsWithStatus(ctx)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("migrations: %s\n", ms)
+				fmt.Printf("unapplied migrations: %s\n", ms.Unapplied())
+				fmt.Printf("last migration group: %s\n", ms.LastGroup())
+				return nil
+			}),
+			createCmd,
+			seedCmd,
+		},
+	}
+}
+
+func (app *App) migrator(db *bun.DB) *migrate.Migrator {
+	migrations := app.migrations
+	if migrations == nil {
+		migrations = migrate.NewMigrations()
+	}
+
+	return migrate.NewMigrator(
+		db,
+		migrations,
+		migrate.WithMarkAppliedOnSuccess(true),
+	)
+}
+
+// createDbCommand loads the configuration and connects to the database
+// before running action, without the tracing, TLS and health checks the
+// servers set up.
+func (app *App) createDbCommand(category string, name string, usage string, action func(ctx context.Context, cmd *cli.Command) error) *cli.Command {
+	return &cli.Command{
+		Name:     name,
+		Usage:    usage,
+		Category: category,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "env",
+				Aliases: []string{"e"},
+				Value:   "local",
+				Usage:   "environment for configuration file",
+			},
+			kvsFlag(),
+			&cli.StringSliceFlag{
+				Name:  "set",
+				Usage: "override a configuration value, as key=value",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			app.loadConfig(cmd.String("env"), cmd.String("kvs"), app.name, cmd.StringSlice("set"))
+			defer app.configHandler.Close()
+
+			if _, err := logging.Setup(app.name, app.config.Logging); err != nil {
+				logging.Fatal("logging setup error", "error", err)
+			}
+
+			if app.config.Dsn == nil {
+				return fmt.Errorf("no database configured for %s", app.name)
+			}
+
+			app.connectDatabase(ctx)
+			defer app.dbHandler.Close()
+
+			return action(ctx, cmd)
+		},
+	}
+}
@@ -0,0 +1,227 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DefaultDevDir = ".certs"
+
+	devValidity = 365 * 24 * time.Hour
+)
+
+// DevCA is a self-signed certificate authority for local development and
+// tests. Certificates it issues are valid for both server and client use.
+type DevCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+func NewDevCA() (*DevCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial(),
+		Subject:               pkix.Name{Organization: []string{"alpha-omega-corp"}, CommonName: "cloud dev ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(devValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDevCA(pemEncode("CERTIFICATE", der), mustMarshalKey(key))
+}
+
+func parseDevCA(certPEM []byte, keyPEM []byte) (*DevCA, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("dev CA key is not an ECDSA key")
+	}
+
+	return &DevCA{
+		cert:    cert,
+		key:     key,
+		CertPEM: certPEM,
+		KeyPEM:  keyPEM,
+	}, nil
+}
+
+// Issue creates a certificate for the given host names and IP addresses.
+func (ca *DevCA) Issue(hosts ...string) (certPEM []byte, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial(),
+		Subject:      pkix.Name{Organization: []string{"alpha-omega-corp"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(devValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	if len(hosts) > 0 {
+		template.Subject.CommonName = hosts[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pemEncode("CERTIFICATE", der), mustMarshalKey(key), nil
+}
+
+// Pool returns a certificate pool trusting only this CA.
+func (ca *DevCA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	return pool
+}
+
+// DevTLS fills the certificate paths of c from the dev CA stored in c.Dir,
+// creating the CA and the certificate of the named service when missing.
+// Services sharing the same directory trust each other.
+func DevTLS(c *types.TLS, name string, hosts ...string) error {
+	dir := c.Dir
+	if dir == "" {
+		dir = DefaultDevDir
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	ca, err := loadOrCreateCA(dir)
+	if err != nil {
+		return err
+	}
+
+	c.CA = filepath.Join(dir, "ca.pem")
+	c.Cert = filepath.Join(dir, name+".pem")
+	c.Key = filepath.Join(dir, name+"-key.pem")
+
+	if exists(c.Cert) && exists(c.Key) {
+		return nil
+	}
+
+	certPEM, keyPEM, err := ca.Issue(append([]string{name, "localhost", "127.0.0.1", "::1"}, hosts...)...)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(c.Key, keyPEM, 0600); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.Cert, certPEM, 0644)
+}
+
+func loadOrCreateCA(dir string) (*DevCA, error) {
+	certPath := filepath.Join(dir, "ca.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+
+	if exists(certPath) && exists(keyPath) {
+		certPEM, err := os.ReadFile(certPath)
+		if err != nil {
+			return nil, err
+		}
+
+		keyPEM, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseDevCA(certPEM, keyPEM)
+	}
+
+	ca, err := NewDevCA()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(keyPath, ca.KeyPEM, 0600); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(certPath, ca.CertPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	slog.Info("generated development CA", "dir", dir)
+	return ca, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func serial() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+func pemEncode(kind string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+}
+
+func mustMarshalKey(key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return pemEncode("EC PRIVATE KEY", der)
+}
@@ -0,0 +1,140 @@
+package security
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"os"
+)
+
+// ServerTLS loads the server certificate. When the configuration is mutual,
+// clients must present a certificate signed by the configured CA.
+func ServerTLS(c *types.TLS) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.Mutual {
+		pool, err := loadPool(c.CA)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// ClientTLS trusts the configured CA, or the system pool when none is set,
+// and presents the client certificate when one is configured.
+func ClientTLS(c *types.TLS) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.CA != "" {
+		pool, err := loadPool(c.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if c.Cert != "" && c.Key != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	} else if c.Mutual {
+		return nil, fmt.Errorf("mutual tls requires a client certificate and key")
+	}
+
+	return config, nil
+}
+
+// ServerCredentials returns the gRPC transport credentials of a server,
+// plaintext when no TLS configuration is set.
+func ServerCredentials(c *types.TLS) (credentials.TransportCredentials, error) {
+	if c == nil {
+		return insecure.NewCredentials(), nil
+	}
+
+	config, err := ServerTLS(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// ClientCredentials returns the gRPC transport credentials of a client,
+// plaintext when no TLS configuration is set.
+func ClientCredentials(c *types.TLS) (credentials.TransportCredentials, error) {
+	if c == nil {
+		return insecure.NewCredentials(), nil
+	}
+
+	config, err := ClientTLS(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no CA certificate configured")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// PeerService returns the name of the service calling over mutual TLS, the
+// common name of its verified client certificate.
+func PeerService(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	leaf := info.State.VerifiedChains[0][0]
+	if leaf.Subject.CommonName != "" {
+		return leaf.Subject.CommonName, true
+	}
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames[0], true
+	}
+
+	return "", false
+}
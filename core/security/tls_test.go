@@ -0,0 +1,144 @@
+package security
+
+import (
+	"context"
+	"github.com/alpha-omega-corp/cloud/core/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+	"time"
+)
+
+// devTLS issues the certificate of the named service from the dev CA of dir.
+func devTLS(t *testing.T, dir string, name string) *types.TLS {
+	t.Helper()
+
+	c := &types.TLS{Dir: dir, ServerName: "user"}
+	if err := DevTLS(c, name); err != nil {
+		t.Fatalf("dev tls %s: %v", name, err)
+	}
+
+	return c
+}
+
+// serve starts a health server over bufconn and returns a dialer for it along
+// with the service name seen on the last call.
+func serve(t *testing.T, c *types.TLS) (func(context.Context, string) (net.Conn, error), *string) {
+	t.Helper()
+
+	creds, err := ServerCredentials(c)
+	if err != nil {
+		t.Fatalf("server credentials: %v", err)
+	}
+
+	seen := new(string)
+	srv := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(
+		func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			*seen, _ = PeerService(ctx)
+			return handler(ctx, req)
+		},
+	))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	listen := bufconn.Listen(1 << 16)
+	go srv.Serve(listen)
+	t.Cleanup(srv.Stop)
+
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		return listen.DialContext(ctx)
+	}, seen
+}
+
+func TestHandshake(t *testing.T) {
+	dir := t.TempDir()
+	user := devTLS(t, dir, "user")
+	docker := devTLS(t, dir, "docker")
+	other := devTLS(t, t.TempDir(), "docker")
+
+	tests := []struct {
+		name    string
+		mutual  bool
+		client  *types.TLS
+		wantErr bool
+		peer    string
+	}{
+		{
+			name:   "tls",
+			client: &types.TLS{CA: user.CA, ServerName: "user"},
+		},
+		{
+			name:   "mtls",
+			mutual: true,
+			client: docker,
+			peer:   "docker",
+		},
+		{
+			name:    "mtls without client certificate",
+			mutual:  true,
+			client:  &types.TLS{CA: user.CA, ServerName: "user"},
+			wantErr: true,
+		},
+		{
+			name:    "mtls with certificate of another ca",
+			mutual:  true,
+			client:  &types.TLS{CA: user.CA, Cert: other.Cert, Key: other.Key, ServerName: "user"},
+			wantErr: true,
+		},
+		{
+			name:    "untrusted server",
+			client:  &types.TLS{CA: other.CA, ServerName: "user"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := *user
+			server.Mutual = tt.mutual
+			dialer, seen := serve(t, &server)
+
+			creds, err := ClientCredentials(tt.client)
+			if err != nil {
+				t.Fatalf("client credentials: %v", err)
+			}
+
+			conn, err := grpc.NewClient("passthrough:///bufconn",
+				grpc.WithContextDialer(dialer),
+				grpc.WithTransportCredentials(creds),
+			)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if tt.wantErr {
+				if status.Code(err) != codes.Unavailable {
+					t.Fatalf("got %v, want a failed handshake", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+
+			if *seen != tt.peer {
+				t.Fatalf("peer service = %q, want %q", *seen, tt.peer)
+			}
+		})
+	}
+}
+
+func TestClientTLSRequiresCertificateForMutual(t *testing.T) {
+	if _, err := ClientTLS(&types.TLS{Mutual: true}); err == nil {
+		t.Fatal("mutual client without a certificate was accepted")
+	}
+}
@@ -1,36 +1,220 @@
 package server
 
 import (
-	"fmt"
+	"context"
 	"github.com/alpha-omega-corp/cloud/core/database"
+	"github.com/alpha-omega-corp/cloud/core/discovery"
+	"github.com/alpha-omega-corp/cloud/core/health"
 	"github.com/uptrace/bun"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"log"
+	"google.golang.org/grpc/credentials"
+	"log/slog"
 	"net"
+	"time"
 )
 
-func NewGRPC(host string, dbHandler *database.Handler, proto func(db *bun.DB, grpc *grpc.Server)) error {
-	listen, err := net.Listen("tcp", host)
+type GRPC struct {
+	host   string
+	srv    *grpc.Server
+	listen net.Listener
 
-	if err != nil {
-		return err
+	health  *health.Server
+	checker *health.Checker
+
+	registry     *clientv3.Client
+	name         string
+	advertise    string
+	registration *discovery.Registration
+
+	creds credentials.TransportCredentials
+
+	timeout  time.Duration
+	recorder Recorder
+	unary    []grpc.UnaryServerInterceptor
+	stream   []grpc.StreamServerInterceptor
+}
+
+type Option func(s *GRPC)
+
+// WithHealth ties the grpc.health.v1 status of the server to the checks.
+func WithHealth(checker *health.Checker) Option {
+	return func(s *GRPC) {
+		s.checker = checker
+	}
+}
+
+// WithRegistry advertises the server in etcd under the service name so that
+// clients can dial it through discovery.Target(name).
+func WithRegistry(client *clientv3.Client, name string, advertise string) Option {
+	return func(s *GRPC) {
+		s.registry = client
+		s.name = name
+		s.advertise = advertise
+	}
+}
+
+// WithCredentials serves over the given transport credentials instead of
+// plaintext, see security.ServerCredentials.
+func WithCredentials(creds credentials.TransportCredentials) Option {
+	return func(s *GRPC) {
+		s.creds = creds
+	}
+}
+
+// WithTimeout sets the deadline applied to calls that do not carry one.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *GRPC) {
+		s.timeout = timeout
+	}
+}
+
+// WithMetrics reports the method, status code and duration of every call.
+func WithMetrics(recorder Recorder) Option {
+	return func(s *GRPC) {
+		s.recorder = recorder
+	}
+}
+
+// WithUnaryInterceptors appends interceptors after the built-in ones, which
+// are in order: request ID, logging, metrics, panic recovery, error mapping,
+// deadline and auth context.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(s *GRPC) {
+		s.unary = append(s.unary, interceptors...)
+	}
+}
+
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(s *GRPC) {
+		s.stream = append(s.stream, interceptors...)
+	}
+}
+
+// WithListener serves on an existing listener instead of listening on the
+// host, for example an in-memory bufconn listener in tests.
+func WithListener(listen net.Listener) Option {
+	return func(s *GRPC) {
+		s.listen = listen
+	}
+}
+
+func NewGRPC(host string, dbHandler *database.Handler, proto func(db *bun.DB, grpc *grpc.Server), opts ...Option) (*GRPC, error) {
+	s := &GRPC{
+		host:      host,
+		advertise: host,
+		checker:   health.NewChecker(),
+		timeout:   DefaultRequestTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.listen == nil {
+		listen, err := net.Listen("tcp", host)
+		if err != nil {
+			return nil, err
+		}
+		s.listen = listen
 	}
 
-	srv := grpc.NewServer()
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(s.unaryChain()...),
+		grpc.ChainStreamInterceptor(s.streamChain()...),
+	}
+
+	if s.creds != nil {
+		serverOpts = append(serverOpts, grpc.Creds(s.creds))
+	}
+
+	s.srv = grpc.NewServer(serverOpts...)
+	s.health = health.Register(s.srv, s.checker)
+
 	if dbHandler != nil {
-		db := dbHandler.Database()
-		defer func(db *bun.DB) {
-			err := db.Close()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}(db)
-
-		proto(db, srv)
+		proto(dbHandler.Database(), s.srv)
 	} else {
-		proto(nil, srv)
+		proto(nil, s.srv)
+	}
+
+	return s, nil
+}
+
+func (s *GRPC) chain() []interceptor {
+	chain := []interceptor{requestIDInterceptor, loggingInterceptor}
+	if s.recorder != nil {
+		chain = append(chain, metricsInterceptor(s.recorder))
 	}
 
-	fmt.Printf("running at tcp://%v", host)
-	return srv.Serve(listen)
+	return append(chain, recoveryInterceptor, errorInterceptor, deadlineInterceptor(s.timeout), authInterceptor)
+}
+
+func (s *GRPC) unaryChain() []grpc.UnaryServerInterceptor {
+	var chain []grpc.UnaryServerInterceptor
+	for _, i := range s.chain() {
+		chain = append(chain, i.unary())
+	}
+
+	return append(chain, s.unary...)
+}
+
+func (s *GRPC) streamChain() []grpc.StreamServerInterceptor {
+	var chain []grpc.StreamServerInterceptor
+	for _, i := range s.chain() {
+		chain = append(chain, i.stream())
+	}
+
+	return append(chain, s.stream...)
+}
+
+// Register advertises the server in the registry, if any. It runs before
+// Serve so that a failure aborts the startup instead of leaving a server
+// that no client can discover.
+func (s *GRPC) Register(ctx context.Context) error {
+	if s.registry == nil {
+		return nil
+	}
+
+	registration, err := discovery.Register(ctx, s.registry, s.name, s.advertise, discovery.DefaultTTL)
+	if err != nil {
+		return err
+	}
+	s.registration = registration
+
+	return nil
+}
+
+func (s *GRPC) Serve() error {
+	s.health.Start()
+
+	slog.Info("grpc server listening", "addr", s.host)
+	return s.srv.Serve(s.listen)
+}
+
+// Shutdown stops accepting new connections and waits for pending RPCs to
+// finish. In-flight calls are cancelled once ctx expires.
+func (s *GRPC) Shutdown(ctx context.Context) error {
+	s.health.Stop()
+
+	if s.registration != nil {
+		if err := s.registration.Deregister(ctx); err != nil {
+			slog.Error("deregister failed", "name", s.name, "error", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/alpha-omega-corp/cloud/core/grpcutils"
+	"github.com/alpha-omega-corp/cloud/core/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	RequestIDHeader     = "x-request-id"
+	AuthorizationHeader = "authorization"
+
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	authTokenKey
+)
+
+// Recorder receives the outcome of every RPC, see WithMetrics.
+type Recorder interface {
+	Observe(method string, code codes.Code, elapsed time.Duration)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// AuthToken returns the bearer token the caller sent in the authorization
+// metadata, if any.
+func AuthToken(ctx context.Context) string {
+	token, _ := ctx.Value(authTokenKey).(string)
+	return token
+}
+
+func WithAuthToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, authTokenKey, token)
+}
+
+// interceptor is the shape shared by the unary and stream chains: it wraps
+// the call context and reports the resulting error.
+type interceptor func(ctx context.Context, method string, next func(ctx context.Context) error) error
+
+func (i interceptor) unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+		err = i(ctx, info.FullMethod, func(ctx context.Context) error {
+			res, err = handler(ctx, req)
+			return err
+		})
+		return res, err
+	}
+}
+
+func (i interceptor) stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return i(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestIDInterceptor reuses the x-request-id sent by the caller or creates
+// one, and echoes it in the response header.
+func requestIDInterceptor(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	id := first(ctx, RequestIDHeader)
+	if id == "" {
+		id = NewRequestID()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+	ctx = logging.With(WithRequestID(ctx, id), logging.KeyRequestID, id, logging.KeyRPC, method)
+	return next(ctx)
+}
+
+func loggingInterceptor(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	start := time.Now()
+	err := next(ctx)
+
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled, codes.NotFound, codes.AlreadyExists, codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied:
+	default:
+		level = slog.LevelError
+	}
+
+	slog.Log(ctx, level, "rpc", "code", code.String(), "duration", time.Since(start))
+	return err
+}
+
+func recoveryInterceptor(ctx context.Context, method string, next func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "rpc panic", "panic", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return next(ctx)
+}
+
+// errorInterceptor translates the errors returned by handlers into status
+// errors, see grpcutils.From. The cause of internal errors is logged.
+func errorInterceptor(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	err := next(ctx)
+	if err == nil {
+		return nil
+	}
+
+	converted := grpcutils.From(err)
+	if status.Code(converted) == codes.Internal && converted != err {
+		slog.ErrorContext(ctx, "rpc failed", "error", err)
+	}
+
+	return converted
+}
+
+func deadlineInterceptor(timeout time.Duration) interceptor {
+	return func(ctx context.Context, method string, next func(ctx context.Context) error) error {
+		if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+			return next(ctx)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return next(ctx)
+	}
+}
+
+func authInterceptor(ctx context.Context, method string, next func(ctx context.Context) error) error {
+	token := first(ctx, AuthorizationHeader)
+	if token == "" {
+		return next(ctx)
+	}
+
+	token, _ = strings.CutPrefix(token, "Bearer ")
+	return next(WithAuthToken(ctx, token))
+}
+
+func metricsInterceptor(recorder Recorder) interceptor {
+	return func(ctx context.Context, method string, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+
+		recorder.Observe(method, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// PropagateUnary forwards the request ID and the auth token of ctx to the
+// called service.
+func PropagateUnary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(propagate(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// PropagateStream is the streaming counterpart of PropagateUnary.
+func PropagateStream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(propagate(ctx), desc, cc, method, opts...)
+	}
+}
+
+func propagate(ctx context.Context) context.Context {
+	if id := RequestID(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDHeader, id)
+	}
+
+	if token := AuthToken(ctx); token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, AuthorizationHeader, "Bearer "+token)
+	}
+
+	return ctx
+}
+
+func first(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,255 @@
+package testing
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	srv "github.com/alpha-omega-corp/cloud/core/server"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dbfixture"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"github.com/uptrace/bun/migrate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+// PostgresEnv names the environment variable holding the DSN of a Postgres
+// server to run the tests against. Each test gets its own schema, dropped
+// once it completes. Without it, tests run on an in-memory SQLite database.
+const PostgresEnv = "CLOUD_TEST_DSN"
+
+const bufSize = 1 << 20
+
+// TB is the subset of testing.TB used by the harness.
+type TB interface {
+	Helper()
+	Cleanup(fn func())
+	Fatalf(format string, args ...any)
+}
+
+type Harness struct {
+	DB   *bun.DB
+	Conn *grpc.ClientConn
+
+	models     []any
+	migrations fs.FS
+	fixtures   fs.FS
+	files      []string
+	options    []srv.Option
+}
+
+type Option func(h *Harness)
+
+// WithModels registers the models of the service, such as the join models
+// of its many-to-many relations.
+func WithModels(models ...any) Option {
+	return func(h *Harness) {
+		h.models = append(h.models, models...)
+	}
+}
+
+// WithMigrations creates the tables with the SQL migrations of the service
+// found in fsys, the ones the db migrate command applies in production.
+func WithMigrations(fsys fs.FS) Option {
+	return func(h *Harness) {
+		h.migrations = fsys
+	}
+}
+
+// WithFixtures loads the YAML fixture files found in fsys once the tables
+// exist, in the format of the db seed command.
+func WithFixtures(fsys fs.FS, files ...string) Option {
+	return func(h *Harness) {
+		h.fixtures = fsys
+		h.files = files
+	}
+}
+
+// WithServerOptions configures the gRPC server, for instance to install the
+// interceptors of the service.
+func WithServerOptions(opts ...srv.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, opts...)
+	}
+}
+
+// New returns an isolated database and a gRPC client connected over an
+// in-memory listener to a server on which register installs the service.
+// The server runs the same interceptors as in production. Everything is
+// released when the test completes.
+func New(t TB, register func(db *bun.DB, grpc *grpc.Server), opts ...Option) *Harness {
+	t.Helper()
+
+	h := &Harness{}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	ctx := context.Background()
+
+	h.DB = openDB(t)
+	h.DB.RegisterModel(h.models...)
+
+	if h.migrations != nil {
+		runMigrations(t, h.DB, h.migrations)
+	}
+
+	if h.fixtures != nil {
+		fixture := dbfixture.New(h.DB)
+		if err := fixture.Load(ctx, h.fixtures, h.files...); err != nil {
+			t.Fatalf("load fixtures: %v", err)
+		}
+	}
+
+	listen := bufconn.Listen(bufSize)
+	grpcSrv, err := srv.NewGRPC("bufconn", nil, func(_ *bun.DB, grpc *grpc.Server) {
+		register(h.DB, grpc)
+	}, append(h.options, srv.WithListener(listen))...)
+	if err != nil {
+		t.Fatalf("create grpc server: %v", err)
+	}
+
+	go func() {
+		_ = grpcSrv.Serve()
+	}()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = grpcSrv.Shutdown(ctx)
+	})
+
+	h.Conn, err = grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listen.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(srv.PropagateUnary()),
+		grpc.WithChainStreamInterceptor(srv.PropagateStream()),
+	)
+	if err != nil {
+		t.Fatalf("dial grpc server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = h.Conn.Close()
+	})
+
+	return h
+}
+
+// sqliteDDL rewrites the Postgres constructs of the migrations that SQLite
+// lacks. An INTEGER primary key is the SQLite equivalent of BIGSERIAL.
+var sqliteDDL = strings.NewReplacer(
+	"BIGSERIAL", "INTEGER",
+	"ADD COLUMN IF NOT EXISTS", "ADD COLUMN",
+	"DROP COLUMN IF EXISTS", "DROP COLUMN",
+)
+
+func runMigrations(t TB, db *bun.DB, fsys fs.FS) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if db.Dialect().Name() == dialect.SQLite {
+		files, err := fs.Glob(fsys, "*.sql")
+		if err != nil {
+			t.Fatalf("list migrations: %v", err)
+		}
+
+		rewritten := make(fstest.MapFS, len(files))
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				t.Fatalf("read migration %s: %v", file, err)
+			}
+			rewritten[file] = &fstest.MapFile{Data: []byte(sqliteDDL.Replace(string(data)))}
+		}
+		fsys = rewritten
+	}
+
+	migrations := migrate.NewMigrations()
+	if err := migrations.Discover(fsys); err != nil {
+		t.Fatalf("discover migrations: %v", err)
+	}
+
+	migrator := migrate.NewMigrator(db, migrations, migrate.WithMarkAppliedOnSuccess(true))
+	if err := migrator.Init(ctx); err != nil {
+		t.Fatalf("init migrations: %v", err)
+	}
+
+	if _, err := migrator.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+}
+
+func openDB(t TB) *bun.DB {
+	t.Helper()
+
+	if dsn := os.Getenv(PostgresEnv); dsn != "" {
+		return openPostgres(t, dsn)
+	}
+
+	// Name the in-memory database after a random suffix so that parallel tests
+	// never share it, and keep a single connection so that it is not lost.
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=foreign_keys(1)", randomSuffix())
+	conn, err := sql.Open(sqliteshim.ShimName, dsn)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	conn.SetMaxOpenConns(1)
+
+	db := bun.NewDB(conn, sqlitedialect.New())
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+func openPostgres(t TB, dsn string) *bun.DB {
+	t.Helper()
+
+	ctx := context.Background()
+	schema := "test_" + randomSuffix()
+
+	admin := bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn))), pgdialect.New())
+	if _, err := admin.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		_ = admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+
+	db := bun.NewDB(sql.OpenDB(pgdriver.NewConnector(
+		pgdriver.WithDSN(dsn),
+		pgdriver.WithConnParams(map[string]interface{}{"search_path": schema}),
+	)), pgdialect.New())
+
+	t.Cleanup(func() {
+		_ = db.Close()
+		_, _ = admin.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		_ = admin.Close()
+	})
+
+	return db
+}
+
+func randomSuffix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -1,9 +1,193 @@
 package types
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const redacted = "[REDACTED]"
 
 type Config struct {
-	Url *string `mapstruct:"url"`
-	Dsn *string `mapstruct:"dsn"`
-	Env *viper.Viper
+	Url       *string      `mapstructure:"url" validate:"required,hostport"`
+	Advertise *string      `mapstructure:"advertise" validate:"hostport"`
+	Dsn       *string      `mapstructure:"dsn" validate:"dsn"`
+	Metrics   *string      `mapstructure:"metrics" validate:"hostport"`
+	Database  *Database    `mapstructure:"database"`
+	TLS       *TLS         `mapstructure:"tls"`
+	Tracing   *Tracing     `mapstructure:"tracing"`
+	Logging   *Logging     `mapstructure:"logging"`
+	Authz     *Authz       `mapstructure:"authz"`
+	Env       *viper.Viper `mapstructure:"-"`
+
+	// Services maps the services this one calls to a static address, dialed
+	// when no registry is available.
+	Services map[string]string `mapstructure:"services"`
+
+	// Secrets lists the keys whose value was resolved from a secret reference.
+	Secrets []string `mapstructure:"-"`
+}
+
+// Database tunes the connection pool. ReplicaDsn routes read-only queries to
+// a replica, ConnectTimeout bounds the retries of the startup ping.
+type Database struct {
+	TLS             *TLS          `mapstructure:"tls"`
+	ReplicaDsn      *string       `mapstructure:"replica_dsn" validate:"dsn"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	ConnectTimeout  time.Duration `mapstructure:"connect_timeout"`
+}
+
+// TLS describes the certificates used by a server or a client. With Mutual
+// set, servers require and verify client certificates signed by CA. Dev
+// generates a local self-signed CA and certificate in Dir.
+type TLS struct {
+	Cert       string `mapstructure:"cert"`
+	Key        string `mapstructure:"key"`
+	CA         string `mapstructure:"ca"`
+	ServerName string `mapstructure:"server_name"`
+	Mutual     bool   `mapstructure:"mutual"`
+	Dev        bool   `mapstructure:"dev"`
+	Dir        string `mapstructure:"dir"`
+}
+
+// Tracing selects where spans are exported: otlp sends them to Endpoint,
+// file appends them as JSON to File and stdout prints them. Ratio is the
+// share of new traces that are sampled, all of them when unset.
+type Tracing struct {
+	Exporter string  `mapstructure:"exporter"`
+	Endpoint string  `mapstructure:"endpoint"`
+	Insecure bool    `mapstructure:"insecure"`
+	File     string  `mapstructure:"file"`
+	Ratio    float64 `mapstructure:"ratio"`
+}
+
+// Logging sets the minimum level (debug, info, warn or error) and the output
+// format (text or json) of the service logs.
+type Logging struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// Authz lists the TrustedServices allowed to act for any user, by the name
+// of the mutual TLS certificate they present. Other services, such as the
+// gateway, are authorized as the user they relay.
+type Authz struct {
+	TrustedServices []string `mapstructure:"trusted_services"`
+}
+
+// String prints the configuration with secrets and DSN passwords redacted.
+func (c *Config) String() string {
+	if c == nil {
+		return "<nil>"
+	}
+
+	var b strings.Builder
+	b.WriteString("{")
+
+	if c.Env != nil {
+		keys := c.Env.AllKeys()
+		sort.Strings(keys)
+
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "%s:%v", key, c.Redact(key, c.Env.Get(key)))
+		}
+	} else {
+		if c.Url != nil {
+			fmt.Fprintf(&b, "url:%s", *c.Url)
+		}
+		if c.Dsn != nil {
+			fmt.Fprintf(&b, " dsn:%s", c.Redact("dsn", *c.Dsn))
+		}
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
+
+// Redact hides the value of key when key or one of its parents is a secret,
+// so that whole subtrees such as keys.* are redacted.
+func (c *Config) Redact(key string, value any) any {
+	for _, secret := range c.Secrets {
+		if key == secret || strings.HasPrefix(key, secret+".") {
+			return redacted
+		}
+	}
+
+	segments := strings.Split(key, ".")
+	for _, segment := range segments {
+		if sensitive(segment) {
+			return redacted
+		}
+	}
+
+	if strings.HasSuffix(segments[len(segments)-1], "dsn") {
+		if raw, ok := value.(string); ok {
+			if u, err := url.Parse(raw); err == nil {
+				return u.Redacted()
+			}
+			return redacted
+		}
+	}
+
+	return value
+}
+
+func sensitive(segment string) bool {
+	if strings.HasSuffix(segment, "_file") {
+		return false
+	}
+
+	for _, word := range []string{"secret", "token", "password", "key"} {
+		if strings.Contains(segment, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Secret is a configuration value that is never printed.
+type Secret string
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// ConfigRef holds the current configuration of a service so that it can be
+// swapped atomically when a new version is published.
+type ConfigRef[T any] struct {
+	value atomic.Pointer[T]
+}
+
+func NewConfigRef[T any](config *T) *ConfigRef[T] {
+	ref := new(ConfigRef[T])
+	ref.Store(config)
+
+	return ref
+}
+
+func (r *ConfigRef[T]) Load() *T {
+	return r.value.Load()
+}
+
+func (r *ConfigRef[T]) Store(config *T) {
+	r.value.Store(config)
 }